@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// apiServer serves the metrics, health, status and sync endpoints
+type apiServer struct {
+	reloader *configReloader
+	state    *syncState
+	// local is set for the control socket, which is protected by file
+	// permissions instead of a token
+	local bool
+}
+
+// statusResponse is the body of /status
+type statusResponse struct {
+	IPv4        string           `json:"ipv4,omitempty"`
+	IPv6        string           `json:"ipv6,omitempty"`
+	Ready       bool             `json:"ready"`
+	LastSync    *time.Time       `json:"last_sync,omitempty"`
+	LastSuccess *time.Time       `json:"last_success,omitempty"`
+	Providers   []providerStatus `json:"providers"`
+}
+
+// syncResponse is the body of /sync
+type syncResponse struct {
+	IPv4      string                 `json:"ipv4,omitempty"`
+	IPv6      string                 `json:"ipv6,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+	Providers []syncProviderResponse `json:"providers"`
+}
+
+// syncProviderResponse is the outcome of syncing one provider record
+type syncProviderResponse struct {
+	Provider   string `json:"provider"`
+	Record     string `json:"record"`
+	RecordType string `json:"record_type"`
+	RecordID   string `json:"record_id,omitempty"`
+	Action     string `json:"action"`
+	OldValue   string `json:"old_value,omitempty"`
+	NewValue   string `json:"new_value"`
+	Error      string `json:"error,omitempty"`
+}
+
+// newAPIMux returns the handlers served on http.listen
+func newAPIMux(reloader *configReloader, state *syncState) *http.ServeMux {
+	api := &apiServer{reloader: reloader, state: state}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", api.healthz)
+	mux.HandleFunc("/readyz", api.readyz)
+	mux.HandleFunc("/status", api.status)
+	mux.HandleFunc("/sync", api.sync)
+	return mux
+}
+
+// newControlMux returns the handlers served on http.socket
+func newControlMux(reloader *configReloader, state *syncState) *http.ServeMux {
+	api := &apiServer{reloader: reloader, state: state, local: true}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", api.status)
+	mux.HandleFunc("/sync", api.sync)
+	return mux
+}
+
+// runAPIServer serves the api on listen until ctx is canceled
+func runAPIServer(ctx context.Context, listen string, reloader *configReloader, state *syncState) {
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		log.Errorf("api: could not listen on %s: %v", listen, err)
+		return
+	}
+	log.Infof("api: listening on %s", listen)
+	serveUntilDone(ctx, listener, newAPIMux(reloader, state), reloader.Settings().shutdownTimeout)
+}
+
+// runControlSocket serves the control api on a unix socket at path until
+// ctx is canceled, only the user running dyngo can use the socket
+func runControlSocket(ctx context.Context, path string, reloader *configReloader, state *syncState) {
+	// a socket left behind by an earlier run would block listening
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		log.Errorf("api: could not listen on %s: %v", path, err)
+		return
+	}
+	defer os.Remove(path)
+	if err := os.Chmod(path, 0600); err != nil {
+		log.Errorf("api: could not set permissions of %s: %v", path, err)
+		listener.Close()
+		return
+	}
+	log.Infof("api: listening on unix socket %s", path)
+	serveUntilDone(ctx, listener, newControlMux(reloader, state), reloader.Settings().shutdownTimeout)
+}
+
+// serveUntilDone serves handler on listener until ctx is canceled, then
+// lets running requests finish for up to shutdownTimeout
+func serveUntilDone(ctx context.Context, listener net.Listener, handler http.Handler,
+	shutdownTimeout time.Duration) {
+	server := &http.Server{Handler: handler}
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		if err := server.Serve(listener); err != http.ErrServerClosed {
+			log.Errorf("api: stopped listening on %s: %v", listener.Addr(), err)
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+	case <-stopped:
+		return
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	server.Shutdown(shutdownCtx)
+}
+
+// healthz answers as long as the process is running
+func (a *apiServer) healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintln(w, "ok")
+}
+
+// readyz answers with an error unless a sync cycle succeeded within the
+// last http.ready_intervals sync intervals
+func (a *apiServer) readyz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	if err := a.ready(); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "not ready: %v\n", err)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// ready returns an error when the last successful sync is too long ago
+func (a *apiServer) ready() error {
+	_, lastSuccess := status.Cycles()
+	if lastSuccess.IsZero() {
+		return fmt.Errorf("no sync has succeeded yet")
+	}
+	settings := a.reloader.Settings()
+	window := time.Duration(settings.readyIntervals) * settings.syncInterval
+	if since := time.Since(lastSuccess); since > window {
+		return fmt.Errorf("last successful sync was %s ago", since.Round(time.Second))
+	}
+	return nil
+}
+
+// status lists the detected addresses and the state of each provider record
+func (a *apiServer) status(w http.ResponseWriter, r *http.Request) {
+	res := statusResponse{
+		IPv4:      status.Address("A"),
+		IPv6:      status.Address("AAAA"),
+		Ready:     a.ready() == nil,
+		Providers: status.Records(a.reloader.Providers(), a.state, a.reloader.Settings().recordTypes),
+	}
+	lastSync, lastSuccess := status.Cycles()
+	if !lastSync.IsZero() {
+		res.LastSync = &lastSync
+	}
+	if !lastSuccess.IsZero() {
+		res.LastSuccess = &lastSuccess
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(res)
+}
+
+// sync runs a sync of all providers, or the ones named by the provider
+// parameters, and returns what it did to each record
+func (a *apiServer) sync(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		fmt.Fprintln(w, "sync must be a POST")
+		return
+	}
+	if !a.local {
+		token := a.reloader.Settings().token
+		if token == "" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprintln(w, "sync is disabled, set http.token to enable it")
+			return
+		}
+		given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			log.Warnf("api: bad sync token from %s", r.RemoteAddr)
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintln(w, "bad token")
+			return
+		}
+	}
+
+	r.ParseForm()
+	names := []string{}
+	for _, value := range r.Form["provider"] {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	providers, err := selectProviders(a.reloader.Providers(), names)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(w, err)
+		return
+	}
+
+	report, err := SyncNow(r.Context(), providers, a.state)
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, err)
+		return
+	}
+
+	res := syncResponse{
+		IPv4:      report.Addresses["A"],
+		IPv6:      report.Addresses["AAAA"],
+		Providers: []syncProviderResponse{},
+	}
+	if report.Err != nil {
+		res.Error = report.Err.Error()
+	}
+	for _, outcome := range report.Outcomes {
+		provider := syncProviderResponse{
+			Provider:   string(outcome.Result.Provider),
+			Record:     outcome.Result.Record,
+			RecordType: outcome.Result.RecordType,
+			RecordID:   outcome.Result.RecordID,
+			Action:     string(outcome.Result.Action),
+			OldValue:   outcome.Result.OldValue,
+			NewValue:   outcome.Result.NewValue,
+		}
+		if outcome.Err != nil {
+			provider.Action = "failed"
+			provider.Error = outcome.Err.Error()
+		}
+		res.Providers = append(res.Providers, provider)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if report.Failed() {
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(res)
+}
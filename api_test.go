@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func apiRequest(mux *http.ServeMux, method string, target string) *httptest.ResponseRecorder {
+	res := httptest.NewRecorder()
+	mux.ServeHTTP(res, httptest.NewRequest(method, target, nil))
+	return res
+}
+
+func TestHealthAndReady(t *testing.T) {
+	defer func(s *serviceStatus) { status = s }(status)
+	status = newServiceStatus()
+	mux := newAPIMux(newConfigReloader(dnsProvidersList{}), nil)
+
+	assert.Equal(t, http.StatusOK, apiRequest(mux, "GET", "/healthz").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, apiRequest(mux, "GET", "/readyz").Code)
+
+	status.CycleDone(true)
+	assert.Equal(t, http.StatusServiceUnavailable, apiRequest(mux, "GET", "/readyz").Code,
+		"failed syncs are not ready")
+	status.CycleDone(false)
+	assert.Equal(t, http.StatusOK, apiRequest(mux, "GET", "/readyz").Code)
+
+	status.lastSuccess = time.Now().Add(-4 * time.Hour)
+	assert.Equal(t, http.StatusServiceUnavailable, apiRequest(mux, "GET", "/readyz").Code,
+		"the last success is more then 3 intervals ago")
+}
+
+func TestStatus(t *testing.T) {
+	defer func(s *serviceStatus) { status = s }(status)
+	status = newServiceStatus()
+
+	state, _ := loadState("")
+	synced := &fakeProvider{record: "home.domain.com", updates: map[string]string{}}
+	broken := &fakeProvider{record: "broken.domain.com", fail: true}
+	remembered := &fakeProvider{record: "old.domain.com"}
+	state.Set(remembered, "A", "192.0.2.9")
+	syncRecords(context.Background(), dnsProvidersList{synced, broken}, state, "A", "192.0.2.1", true)
+	observeAddress("A", "192.0.2.1")
+
+	mux := newAPIMux(newConfigReloader(dnsProvidersList{synced, broken, remembered}), state)
+	res := apiRequest(mux, "GET", "/status")
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var body statusResponse
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+	assert.Equal(t, "192.0.2.1", body.IPv4)
+	assert.Len(t, body.Providers, 6)
+
+	records := map[string]providerStatus{}
+	for _, record := range body.Providers {
+		records[record.Record+"/"+record.RecordType] = record
+	}
+	assert.Equal(t, "192.0.2.1", records["home.domain.com/A"].Value)
+	assert.Equal(t, "updated", records["home.domain.com/A"].LastAction)
+	assert.NotNil(t, records["home.domain.com/A"].LastAttempt)
+	assert.Equal(t, "fake failure", records["broken.domain.com/A"].LastError)
+	assert.Equal(t, "192.0.2.9", records["old.domain.com/A"].Value)
+	assert.Nil(t, records["old.domain.com/A"].LastAttempt)
+}
+
+func newTestSync(t *testing.T) (*http.ServeMux, []*fakeProvider, func()) {
+	ipServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "198.51.100.7")
+	}))
+	viper.Set("ip_check.ipv4_urls", []string{ipServer.URL})
+	viper.Set("ip_check.ipv6", false)
+	viper.Set("http.token", "secret")
+
+	providers := []*fakeProvider{
+		{record: "home.domain.com", updates: map[string]string{}},
+		{record: "other.domain.com", updates: map[string]string{}},
+	}
+	state, _ := loadState("")
+	mux := newAPIMux(newConfigReloader(dnsProvidersList{providers[0], providers[1]}), state)
+	return mux, providers, func() {
+		ipServer.Close()
+		viper.Set("ip_check.ipv4_urls", nil)
+		viper.Set("ip_check.ipv6", nil)
+		viper.Set("http.token", nil)
+	}
+}
+
+func syncRequest(mux *http.ServeMux, token string, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/sync?"+query, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res := httptest.NewRecorder()
+	mux.ServeHTTP(res, req)
+	return res
+}
+
+func TestSyncAuth(t *testing.T) {
+	mux, _, cleanup := newTestSync(t)
+	defer cleanup()
+
+	assert.Equal(t, http.StatusMethodNotAllowed, apiRequest(mux, "GET", "/sync").Code)
+	assert.Equal(t, http.StatusUnauthorized, syncRequest(mux, "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, syncRequest(mux, "wrong", "").Code)
+
+	viper.Set("http.token", "")
+	mux = newAPIMux(newConfigReloader(dnsProvidersList{}), nil)
+	assert.Equal(t, http.StatusForbidden, syncRequest(mux, "", "").Code,
+		"sync is disabled without a token")
+}
+
+func TestSync(t *testing.T) {
+	mux, providers, cleanup := newTestSync(t)
+	defer cleanup()
+
+	res := syncRequest(mux, "secret", "provider=other.domain.com")
+	assert.Equal(t, http.StatusOK, res.Code)
+	var body syncResponse
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+	assert.Equal(t, "198.51.100.7", body.IPv4)
+	assert.Equal(t, []syncProviderResponse{{
+		Provider:   "fake",
+		Record:     "other.domain.com",
+		RecordType: "A",
+		Action:     "updated",
+		NewValue:   "198.51.100.7",
+	}}, body.Providers)
+	assert.Equal(t, 0, providers[0].calls)
+
+	// synced records are still sent to the providers
+	res = syncRequest(mux, "secret", "")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, 1, providers[0].calls)
+	assert.Equal(t, 2, providers[1].calls)
+
+	assert.Equal(t, http.StatusBadRequest, syncRequest(mux, "secret", "provider=nope").Code)
+}
+
+func TestSyncWaitsForRunningSync(t *testing.T) {
+	mux, providers, cleanup := newTestSync(t)
+	defer cleanup()
+
+	syncCycle <- struct{}{}
+	done := make(chan int)
+	go func() { done <- syncRequest(mux, "secret", "").Code }()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 0, providers[0].calls, "sync should wait for the running sync")
+	<-syncCycle
+	assert.Equal(t, http.StatusOK, <-done)
+	assert.Equal(t, 1, providers[0].calls)
+}
+
+func TestControlSocket(t *testing.T) {
+	_, providers, cleanup := newTestSync(t)
+	defer cleanup()
+	dir, err := ioutil.TempDir("", "dyngo")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "dyngo.sock")
+
+	state, _ := loadState("")
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		runControlSocket(ctx, path, newConfigReloader(dnsProvidersList{providers[0]}), state)
+		close(stopped)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+	for i := 0; i < 50; i++ {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return net.Dial("unix", path)
+		},
+	}}
+	res, err := client.Post("http://dyngo/sync", "", nil)
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode, "the socket does not need a token")
+	assert.Equal(t, 1, providers[0].calls)
+}
@@ -1,7 +1,13 @@
 package main
 
 import (
+	"strings"
+	"time"
+
 	"github.com/gesquive/dyngo/dns"
+	"github.com/gesquive/dyngo/ipcheck"
+	"github.com/gesquive/dyngo/notify"
+	"github.com/pkg/errors"
 	"github.com/spf13/viper"
 )
 
@@ -9,25 +15,207 @@ import (
 type dnsProvidersList []dns.Provider
 
 func getDNSProviders() (dnsProvidersList, error) {
+	dnsPrv, policies, err := readDNSProviders()
+	if err != nil {
+		return dnsPrv, err
+	}
+	retries.SetPolicies(policies)
+	return dnsPrv, nil
+}
+
+// readDNSProviders returns the providers in the config and their retry
+// policies, without setting the policies
+func readDNSProviders() (dnsProvidersList, map[dns.Provider]retryPolicy, error) {
 	if ! viper.IsSet("dns_providers") {
 		var dnsPrv dnsProvidersList
-		return dnsPrv, nil
+		return dnsPrv, nil, nil
 	}
 
 	var dnsConfigs []map[string]string
 	err := viper.UnmarshalKey("dns_providers", &dnsConfigs)
 	if err != nil {
-		return dnsProvidersList{}, err
+		return dnsProvidersList{}, nil, err
 	}
 
 	dnsPrv := make(dnsProvidersList, len(dnsConfigs))
+	policies := map[dns.Provider]retryPolicy{}
 	for i, providerConfig := range dnsConfigs {
 		dnsProvider, err := dns.GetDNSProvider(providerConfig)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+		policy, err := getRetryPolicy(providerConfig)
+		if err != nil {
+			return nil, nil, err
 		}
+		policies[dnsProvider] = policy
 		dnsPrv[i] = dnsProvider
 	}
 
-	return dnsPrv, nil
+	return dnsPrv, policies, nil
+}
+
+// getNotifiers returns the notifiers in the config and the events each
+// one is sent
+func getNotifiers() ([]notify.Subscription, error) {
+	if !viper.IsSet("notifications.notifiers") {
+		return nil, nil
+	}
+
+	var notifierConfigs []notify.Config
+	err := viper.UnmarshalKey("notifications.notifiers", &notifierConfigs)
+	if err != nil {
+		return nil, err
+	}
+
+	subscriptions := make([]notify.Subscription, len(notifierConfigs))
+	for i, notifierConfig := range notifierConfigs {
+		notifier, events, err := notify.GetNotifier(notifierConfig)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions[i] = notify.Subscription{Notifier: notifier, Events: events}
+	}
+	return subscriptions, nil
+}
+
+// getHooks returns the global hooks, and the on_update and on_failure
+// hooks of each provider limited to its record
+func getHooks() ([]notify.Subscription, error) {
+	timeout, err := time.ParseDuration(viper.GetString("hooks.timeout"))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid hooks.timeout")
+	}
+
+	subscriptions := []notify.Subscription{}
+	for _, hook := range hookEvents {
+		configs, err := notify.ParseHookConfigs(viper.Get("hooks." + hook.key))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid hooks.%s", hook.key)
+		}
+		for _, config := range configs {
+			subscriptions = append(subscriptions, notify.Subscription{
+				Notifier: notify.NewHook(config, timeout),
+				Events:   hook.events,
+			})
+		}
+	}
+
+	var dnsConfigs []map[string]string
+	if err := viper.UnmarshalKey("dns_providers", &dnsConfigs); err != nil {
+		return nil, err
+	}
+	for _, providerConfig := range dnsConfigs {
+		for _, hook := range hookEvents {
+			line := providerConfig[hook.key]
+			if line == "" {
+				continue
+			}
+			if hook.key == "on_ip_change" {
+				return nil, errors.Errorf("on_ip_change of %s is only supported under hooks",
+					providerConfig["record"])
+			}
+			configs, err := notify.ParseHookConfigs(line)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid %s of %s", hook.key, providerConfig["record"])
+			}
+			for _, config := range configs {
+				subscriptions = append(subscriptions, notify.Subscription{
+					Notifier: notify.NewHook(config, timeout),
+					Events:   hook.events,
+					Provider: strings.TrimSpace(providerConfig["name"]),
+					Record:   providerConfig["record"],
+				})
+			}
+		}
+	}
+	return subscriptions, nil
+}
+
+// selectProviders returns the providers whose name or record is in names,
+// or all of them when names is empty
+func selectProviders(dnsProviders dnsProvidersList, names []string) (dnsProvidersList, error) {
+	if len(names) == 0 {
+		return dnsProviders, nil
+	}
+	selected := dnsProvidersList{}
+	found := map[string]bool{}
+	for _, provider := range dnsProviders {
+		matched := false
+		for _, name := range names {
+			if strings.EqualFold(string(provider.GetName()), name) ||
+				strings.EqualFold(provider.GetRecord(), name) {
+				found[name] = true
+				matched = true
+			}
+		}
+		if matched {
+			selected = append(selected, provider)
+		}
+	}
+	for _, name := range names {
+		if !found[name] {
+			return nil, errors.Errorf("no provider named '%s'", name)
+		}
+	}
+	return selected, nil
+}
+
+// getIPSources returns the ip check sources configured for the family
+func getIPSources(family ipcheck.Family) ([]ipcheck.Source, error) {
+	key := "ip_check.ipv4_urls"
+	if family == ipcheck.IPv6 {
+		key = "ip_check.ipv6_urls"
+	}
+	configs, err := ipcheck.ParseSourceConfigs(viper.Get(key))
+	if err != nil {
+		return nil, err
+	}
+	return ipcheck.GetSources(configs, family)
+}
+
+// validateConfig checks the settings that are read during each sync, so a
+// bad config is found before it is used
+func validateConfig() error {
+	checkIPv4 := viper.GetBool("ip_check.ipv4")
+	checkIPv6 := viper.GetBool("ip_check.ipv6")
+	if !checkIPv4 && !checkIPv6 {
+		return errors.New("IP checks for both IPv4 & IPv6 are turned off")
+	}
+	if checkIPv4 {
+		if _, err := getIPSources(ipcheck.IPv4); err != nil {
+			return errors.Wrap(err, "could not parse ipv4_urls")
+		}
+	}
+	if checkIPv6 {
+		if _, err := getIPSources(ipcheck.IPv6); err != nil {
+			return errors.Wrap(err, "could not parse ipv6_urls")
+		}
+	}
+
+	quorumSize := viper.GetInt("ip_check.quorum.sources")
+	quorumAgree := viper.GetInt("ip_check.quorum.min_agree")
+	if quorumSize > 0 && (quorumAgree < 1 || quorumAgree > quorumSize) {
+		return errors.Errorf("quorum min_agree must be between 1 and %d", quorumSize)
+	}
+
+	for _, key := range []string{"service.verify_interval", "service.provider_timeout",
+		"service.shutdown_timeout", "notifications.remind_interval", "notifications.timeout"} {
+		if _, err := time.ParseDuration(viper.GetString(key)); err != nil {
+			return errors.Wrapf(err, "the given value is invalid %s=%s", key, viper.GetString(key))
+		}
+	}
+	if _, err := getRetryPolicy(dns.ProviderConfig{}); err != nil {
+		return err
+	}
+	if _, err := getNotifiers(); err != nil {
+		return errors.Wrap(err, "could not parse notifications")
+	}
+	if _, err := getHooks(); err != nil {
+		return errors.Wrap(err, "could not parse hooks")
+	}
+	if _, err := getPublisher(); err != nil {
+		return errors.Wrap(err, "could not parse mqtt")
+	}
+	return nil
 }
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"testing"
 
+	"github.com/gesquive/dyngo/ipcheck"
+	"github.com/gesquive/dyngo/notify"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 )
@@ -44,3 +46,171 @@ func TestDefaultIpCheck(t *testing.T) {
 	assert.True(t, viper.GetBool("ip_check.ipv6"))
 	assert.Len(t, viper.GetStringSlice("ip_check.ipv6_urls"), 0, "IPv6Url count does not match")
 }
+
+func TestQuorumIpCheck(t *testing.T) {
+	str := []byte(
+		`ip_check:
+    quorum:
+        sources: 3
+        min_agree: 2
+`)
+
+	viper.SetConfigType("yaml")
+	err := viper.ReadConfig(bytes.NewBuffer(str))
+	assert.NoError(t, err, "error reading conf")
+
+	assert.Equal(t, 3, viper.GetInt("ip_check.quorum.sources"))
+	assert.Equal(t, 2, viper.GetInt("ip_check.quorum.min_agree"))
+}
+
+func TestStructuredIpCheck(t *testing.T) {
+	str := []byte(
+		`ip_check:
+    ipv4_urls:
+    - "http://ipv4-1.net"
+    - url: "http://ipv4-2.net/json"
+      extract: json
+      path: ip
+      timeout: 5s
+    ipv6_urls:
+    - url: "http://ipv6-1.net"
+      extract: regex
+      pattern: "Address: (.*)"
+      headers:
+        Accept: text/html
+`)
+
+	viper.SetConfigType("yaml")
+	err := viper.ReadConfig(bytes.NewBuffer(str))
+	assert.NoError(t, err, "error reading conf")
+
+	sources, err := getIPSources(ipcheck.IPv4)
+	assert.NoError(t, err)
+	assert.Len(t, sources, 2, "IPv4Url count does not match")
+	sources, err = getIPSources(ipcheck.IPv6)
+	assert.NoError(t, err)
+	assert.Len(t, sources, 1, "IPv6Url count does not match")
+}
+
+func TestNotifiersConfig(t *testing.T) {
+	str := []byte(
+		`notifications:
+  notifiers:
+    - type: webhook
+      url: "http://localhost:8080/hook"
+      method: PUT
+      events:
+        - sync_failed
+      headers:
+        Authorization: Bearer secret
+      body: '{"text": "{{.Message}}"}'
+`)
+
+	viper.SetConfigType("yaml")
+	err := viper.ReadConfig(bytes.NewBuffer(str))
+	assert.NoError(t, err, "error reading conf")
+	defer viper.ReadConfig(bytes.NewBufferString("{}"))
+
+	subscriptions, err := getNotifiers()
+	assert.NoError(t, err)
+	if assert.Len(t, subscriptions, 1) {
+		assert.Equal(t, notify.Name("webhook"), subscriptions[0].Notifier.GetName())
+		assert.Equal(t, []notify.EventType{notify.SyncFailed}, subscriptions[0].Events)
+	}
+}
+
+func TestHooksConfig(t *testing.T) {
+	str := []byte(
+		`hooks:
+  timeout: 10s
+  on_ip_change:
+    - /usr/local/bin/restart-wg.sh wg0
+  on_update:
+    - command: /usr/local/bin/flush.sh
+      timeout: 5s
+dns_providers:
+  - name: custom
+    record: home.domain.com
+    path: /bin/true
+    on_failure: /usr/local/bin/page.sh
+`)
+
+	viper.SetConfigType("yaml")
+	err := viper.ReadConfig(bytes.NewBuffer(str))
+	assert.NoError(t, err, "error reading conf")
+	defer viper.ReadConfig(bytes.NewBufferString("{}"))
+
+	subscriptions, err := getHooks()
+	assert.NoError(t, err)
+	if assert.Len(t, subscriptions, 3) {
+		assert.Equal(t, []notify.EventType{notify.IPChanged}, subscriptions[0].Events)
+		assert.Equal(t, []notify.EventType{notify.RecordUpdated, notify.RecordCreated}, subscriptions[1].Events)
+		assert.Equal(t, []notify.EventType{notify.SyncFailed}, subscriptions[2].Events)
+		assert.Equal(t, "custom", subscriptions[2].Provider)
+		assert.Equal(t, "home.domain.com", subscriptions[2].Record)
+	}
+
+	// the address is not per provider, so neither is on_ip_change
+	str = []byte(
+		`dns_providers:
+  - name: custom
+    record: home.domain.com
+    path: /bin/true
+    on_ip_change: /usr/local/bin/restart-wg.sh wg0
+`)
+	assert.NoError(t, viper.ReadConfig(bytes.NewBuffer(str)))
+	_, err = getHooks()
+	assert.Error(t, err)
+}
+
+func TestMQTTConfig(t *testing.T) {
+	viper.SetConfigType("yaml")
+	defer viper.ReadConfig(bytes.NewBufferString("{}"))
+
+	assert.NoError(t, viper.ReadConfig(bytes.NewBufferString("{}")))
+	p, err := getPublisher()
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+
+	str := []byte(
+		`mqtt:
+  broker: tcp://localhost:1883
+  qos: 1
+  timeout: 5s
+  events:
+    - sync_failed
+`)
+	assert.NoError(t, viper.ReadConfig(bytes.NewBuffer(str)))
+	p, err = getPublisher()
+	assert.NoError(t, err)
+	if assert.NotNil(t, p) {
+		assert.Equal(t, []notify.EventType{notify.SyncFailed}, p.Events())
+	}
+
+	assert.NoError(t, viper.ReadConfig(bytes.NewBufferString("mqtt:\n  broker: tcp://localhost:1883\n  qos: 3\n")))
+	_, err = getPublisher()
+	assert.Error(t, err)
+}
+
+func TestRetryConfig(t *testing.T) {
+	viper.SetConfigType("yaml")
+	defer viper.ReadConfig(bytes.NewBufferString("{}"))
+
+	assert.NoError(t, viper.ReadConfig(bytes.NewBufferString("{}")))
+	assert.NoError(t, validateConfig())
+	for _, retry := range []string{"jitter: 1.5", "jitter: -0.1", "initial_backoff: 0s",
+		"max_backoff: -1m"} {
+		assert.NoError(t, viper.ReadConfig(bytes.NewBufferString("service:\n  retry:\n    "+retry+"\n")))
+		assert.Error(t, validateConfig(), retry)
+	}
+
+	// provider overrides are checked when the providers are read
+	assert.NoError(t, viper.ReadConfig(bytes.NewBufferString(`dns_providers:
+  - name: custom
+    path: /bin/true
+    record: home.domain.com
+    retry_initial_backoff: 0s
+`)))
+	_, err := getDNSProviders()
+	assert.Error(t, err)
+}
@@ -1,52 +1,273 @@
 package main
 
 import (
-	"fmt"
-	"io/ioutil"
-	"math/rand"
-	"net"
-	"net/http"
-	"strings"
+	"context"
+	"sync"
 	"time"
 
+	"github.com/gesquive/dyngo/dns"
+	"github.com/gesquive/dyngo/ipcheck"
+	"github.com/pkg/errors"
 	"github.com/spf13/viper"
 )
 
-// RunService runs as a service
-func RunService(dns dnsProvidersList, syncInterval time.Duration) {
+// syncCycle holds a token while a sync is running, so cycles never overlap
+var syncCycle = make(chan struct{}, 1)
+
+// syncGroup runs the sync cycles of the service, including the ones
+// requested over the api, so a shutdown can wait for them. Syncs get their
+// own context, so a shutdown lets them finish cleanly instead of abandoning
+// provider updates halfway
+type syncGroup struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+	stopped bool
+	mutex   sync.Mutex
+}
+
+// syncs holds the sync cycles of the running service
+var syncs = newSyncGroup()
+
+func newSyncGroup() *syncGroup {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &syncGroup{ctx: ctx, cancel: cancel}
+}
+
+// Go starts sync in the background, unless the group is stopped
+func (g *syncGroup) Go(sync func(ctx context.Context)) {
+	if !g.add() {
+		return
+	}
+	go func() {
+		defer g.running.Done()
+		sync(g.ctx)
+	}()
+}
+
+// Run runs sync and returns true, or returns false if the group is stopped
+func (g *syncGroup) Run(sync func(ctx context.Context)) bool {
+	if !g.add() {
+		return false
+	}
+	defer g.running.Done()
+	sync(g.ctx)
+	return true
+}
+
+func (g *syncGroup) add() bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.stopped {
+		return false
+	}
+	g.running.Add(1)
+	return true
+}
+
+// Stop refuses new syncs, syncs that are already running are left to finish
+func (g *syncGroup) Stop() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.stopped = true
+}
+
+// Cancel cancels the context of the running syncs
+func (g *syncGroup) Cancel() {
+	g.cancel()
+}
+
+// Wait blocks until the running syncs are done
+func (g *syncGroup) Wait() {
+	g.running.Wait()
+}
+
+// RunService runs a sync of the reloaders providers every syncInterval until
+// ctx is canceled, then waits up to shutdownTimeout for the running syncs,
+// retries, notifications and hooks to finish
+func RunService(ctx context.Context, reloader *configReloader, state *syncState,
+	syncInterval time.Duration, shutdownTimeout time.Duration) {
 	log.Infof("service: run as service every %s", syncInterval)
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+	defer syncs.Cancel()
+
+	startSync := func() {
+		syncs.Go(func(ctx context.Context) {
+			trySyncDomain(ctx, reloader.Providers(), state)
+		})
+	}
+
+	startSync()
 	for {
-		go SyncDomain(dns)
-		time.Sleep(syncInterval)
+		select {
+		case <-ticker.C:
+			startSync()
+		case <-ctx.Done():
+			log.Infof("service: shutting down")
+			ticker.Stop()
+			syncs.Stop()
+			retries.Stop()
+			if !waitFor(shutdownTimeout, syncs.Wait, retries.Wait, notifications.Flush, hooks.Wait) {
+				log.Warnf("service: syncs still running after %s, canceling them", shutdownTimeout)
+				syncs.Cancel()
+				waitFor(shutdownTimeout, syncs.Wait, retries.Wait, notifications.Flush, hooks.Wait)
+			}
+			if err := state.Save(); err != nil {
+				log.Errorf("service: could not save state file err=%s", err)
+			}
+			log.Infof("service: stopped")
+			return
+		}
+	}
+}
+
+// waitFor runs each wait in turn, and returns false if they did not all
+// return within timeout
+func waitFor(timeout time.Duration, waits ...func()) bool {
+	done := make(chan struct{})
+	go func() {
+		for _, wait := range waits {
+			wait()
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
 	}
 }
 
-// RunSync syncs your public IP with the given domain
-func RunSync(dns dnsProvidersList) {
+// RunSync syncs your public IP with the given domain, and waits for any
+// retries, notifications and hooks to finish unless ctx is canceled
+func RunSync(ctx context.Context, dns dnsProvidersList, state *syncState) {
 	log.Infof("update: Updating record for %d providers", len(dns))
-	SyncDomain(dns)
+	SyncDomain(ctx, dns, state)
+
+	done := make(chan struct{})
+	go func() {
+		retries.Wait()
+		notifications.Flush()
+		hooks.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		retries.Stop()
+	}
+}
+
+// trySyncDomain runs SyncDomain unless a sync is already running, and
+// returns false if it was skipped
+func trySyncDomain(ctx context.Context, dnsProviders dnsProvidersList, state *syncState) bool {
+	select {
+	case syncCycle <- struct{}{}:
+		defer func() { <-syncCycle }()
+	default:
+		log.Warnf("service: previous sync is still running, skipping this one")
+		syncCycles.WithLabelValues("skipped").Inc()
+		return false
+	}
+	SyncDomain(ctx, dnsProviders, state)
+	return true
+}
+
+// SyncNow runs a sync that calls every provider once any running sync is
+// done, waiting gives up when ctx is canceled. The sync is one of the
+// service syncs, so it is refused once the service is shutting down
+func SyncNow(ctx context.Context, dnsProviders dnsProvidersList, state *syncState) (syncReport, error) {
+	select {
+	case syncCycle <- struct{}{}:
+		defer func() { <-syncCycle }()
+	case <-ctx.Done():
+		return syncReport{}, ctx.Err()
+	}
+	var report syncReport
+	ran := syncs.Run(func(syncCtx context.Context) {
+		log.Infof("sync: running a requested sync of %d providers", len(dnsProviders))
+		report = syncDomain(syncCtx, dnsProviders, state, true)
+	})
+	if !ran {
+		return report, errors.New("the service is shutting down")
+	}
+	return report, nil
+}
+
+// SyncDomain sets a domain record point to our public IP address
+func SyncDomain(ctx context.Context, dnsProviders dnsProvidersList, state *syncState) {
+	syncDomain(ctx, dnsProviders, state, false)
+}
+
+// syncOutcome is the result of syncing a single provider record
+type syncOutcome struct {
+	Result dns.Result
+	Err    error
+}
+
+// syncReport is what a sync cycle did
+type syncReport struct {
+	// Addresses are the detected public addresses by record type
+	Addresses map[string]string
+	Outcomes  []syncOutcome
+	// Err is set when a public address could not be detected
+	Err error
+}
+
+// Failed returns true if the address check or any provider failed
+func (r syncReport) Failed() bool {
+	return r.Err != nil || anyFailed(r.Outcomes)
+}
+
+// anyFailed returns true if any of the outcomes is a failure
+func anyFailed(outcomes []syncOutcome) bool {
+	for _, outcome := range outcomes {
+		if outcome.Err != nil {
+			return true
+		}
+	}
+	return false
 }
 
-//SyncDomain sets a domain record point to our public IP address
-func SyncDomain(dnsProviders dnsProvidersList) {
+// syncDomain runs a sync cycle, force calls every provider even when the
+// state says its record is already set
+func syncDomain(ctx context.Context, dnsProviders dnsProvidersList, state *syncState,
+	force bool) (report syncReport) {
+	report.Addresses = map[string]string{}
 	setIPv4 := viper.GetBool("ip_check.ipv4")
 	setIPv6 := viper.GetBool("ip_check.ipv6")
 	if !setIPv4 && !setIPv6 {
 		log.Warnf("All IP checks are turned off, no sync")
 	}
+
+	// Every so often, ignore the state and check every record with the
+	// providers so changes made outside of dyngo get corrected
+	verifyInterval, _ := time.ParseDuration(viper.GetString("service.verify_interval"))
+	verify := !force && state.NeedsVerify(verifyInterval)
+	if verify {
+		log.Infof("sync: verifying all records with the dns providers")
+	}
+
 	if setIPv4 {
 		// First get our public IP
 		currentIP, err := getPublicIPv4Address()
 		if err != nil {
 			log.Errorf("sync: could not get public ipv4 address")
 			log.Errorf("sync: err=%s", err)
-			return
+			notifyLookupFailed("A", err)
+			observeSyncCycle(true)
+			report.Err = err
+			return report
 		}
+		notifyAddress(state, "A", currentIP)
+		observeAddress("A", currentIP)
+		report.Addresses["A"] = currentIP
 
 		// Second, update all DNS providers
-		for _, provider := range dnsProviders {
-			provider.SyncARecord(currentIP)
-		}
+		report.Outcomes = append(report.Outcomes,
+			syncRecords(ctx, dnsProviders, state, "A", currentIP, verify || force)...)
 	}
 
 	if setIPv6 {
@@ -55,94 +276,182 @@ func SyncDomain(dnsProviders dnsProvidersList) {
 		if err != nil {
 			log.Errorf("sync: could not get public ipv6 address")
 			log.Errorf("sync: err=%s", err)
-			return
+			notifyLookupFailed("AAAA", err)
+			observeSyncCycle(true)
+			report.Err = err
+			return report
 		}
+		notifyAddress(state, "AAAA", currentIP)
+		observeAddress("AAAA", currentIP)
+		report.Addresses["AAAA"] = currentIP
 
 		// Second, update all DNS providers
-		for _, provider := range dnsProviders {
-			provider.SyncAAAARecord(currentIP)
-		}
+		report.Outcomes = append(report.Outcomes,
+			syncRecords(ctx, dnsProviders, state, "AAAA", currentIP, verify || force)...)
+	}
+
+	failed := report.Failed()
+	if verify && !failed {
+		state.Verified()
+	}
+	observeSyncCycle(failed)
+	if err := state.Save(); err != nil {
+		log.Errorf("sync: could not save state file err=%s", err)
 	}
+	return report
 }
 
-func getPublicIPv4Address() (ipAddress string, err error) {
-	maxAttempts := 3
-	ipCheckServices := viper.GetStringSlice("ip_check.ipv4_urls")
-	rand.Seed(time.Now().Unix())
-	gotIP := false
-
-	for i := 0; i < maxAttempts && !gotIP; i++ {
-		victim := rand.Intn(len(ipCheckServices))
-		url := ipCheckServices[victim]
-		log.Infof("ipchk4: using '%s' for ip check", url)
-
-		response, herr := http.Get(url)
-		if herr != nil {
-			log.Errorf("ipchk4: Failed to get ip from '%s'", url)
-			log.Errorf("ipchk4: err=%s", herr)
-			continue
-		}
-		defer response.Body.Close()
-		body, berr := ioutil.ReadAll(response.Body)
-		if berr != nil {
-			log.Errorf("ipchk4: Could not read response from '%s'", url)
-			log.Errorf("ipchk4: err=%s", berr)
-			continue
-		}
-		ipAddress = strings.TrimSpace(string(body))
-		if ip := net.ParseIP(ipAddress); ip == nil || ip.To4() == nil {
-			log.Errorf("ipchk4: response is not a valid IPv4 address. response='%s'",
-				ipAddress)
-			ipAddress = ""
+// syncRecords updates the recordType record of each provider to ipAddress,
+// providers that already have it are skipped unless verify is set, and the
+// outcome of each provider that was called is returned. Providers are synced
+// in parallel by service.workers workers, each limited to
+// service.provider_timeout
+func syncRecords(ctx context.Context, dnsProviders dnsProvidersList, state *syncState,
+	recordType string, ipAddress string, verify bool) []syncOutcome {
+	timeout, _ := time.ParseDuration(viper.GetString("service.provider_timeout"))
+	workers := viper.GetInt("service.workers")
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan dns.Provider)
+	results := make(chan syncOutcome, len(dnsProviders))
+	var wg sync.WaitGroup
+	for i := 0; i < workers && i < len(dnsProviders); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for provider := range jobs {
+				retries.Cancel(stateKey(provider, recordType))
+				results <- syncRecord(ctx, provider, state, recordType, ipAddress, timeout, 1)
+			}
+		}()
+	}
+
+	for _, provider := range dnsProviders {
+		if !verify && state.Get(provider, recordType) == ipAddress {
+			log.Debugf("sync: %s record=%s type=%s already set to ip=%s, skipping",
+				provider.GetName(), provider.GetRecord(), recordType, ipAddress)
 			continue
 		}
-		gotIP = true
+		jobs <- provider
+	}
+	close(jobs)
+	wg.Wait()
+	close(results)
+
+	outcomes := []syncOutcome{}
+	for outcome := range results {
+		outcomes = append(outcomes, outcome)
 	}
-	if !gotIP {
-		err = fmt.Errorf("ipchk4: ran out of attempts to get IP address")
+	return outcomes
+}
+
+// syncRecord syncs a single provider record, giving up after timeout.
+// Failures that may go away are retried later following the providers
+// retry policy
+func syncRecord(ctx context.Context, provider dns.Provider, state *syncState,
+	recordType string, ipAddress string, timeout time.Duration, attempt int) syncOutcome {
+	syncCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		syncCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
 
-	log.Infof("ipchk: got public IP address=%s", ipAddress)
-	return ipAddress, err
+	start := time.Now()
+	result, err := provider.SyncRecord(syncCtx, recordType, ipAddress)
+	observeProviderSync(provider, recordType, time.Since(start), result, err)
+	if result.Provider == "" {
+		result = dns.NewResult(provider, recordType, ipAddress)
+	}
+	if err == nil {
+		logResult(result)
+		notifyResult(result)
+		state.Set(provider, recordType, ipAddress)
+		return syncOutcome{Result: result}
+	}
+	log.Errorf("sync: %s record=%s type=%s failed attempt=%d err=%s",
+		provider.GetName(), provider.GetRecord(), recordType, attempt, err)
+	state.Forget(provider, recordType)
+
+	policy := retries.Policy(provider)
+	switch {
+	case dns.IsPermanent(err):
+		log.Warnf("sync: %s record=%s type=%s will not be retried until the next sync",
+			provider.GetName(), provider.GetRecord(), recordType)
+		notifyFailed(result, err)
+	case ctx.Err() != nil:
+	case attempt >= policy.Attempts:
+		log.Warnf("sync: %s record=%s type=%s gave up after %d attempts",
+			provider.GetName(), provider.GetRecord(), recordType, attempt)
+		notifyFailed(result, err)
+	default:
+		delay := policy.backoff(attempt)
+		log.Infof("sync: %s record=%s type=%s retrying in %s",
+			provider.GetName(), provider.GetRecord(), recordType, delay.Round(time.Second))
+		retries.Schedule(stateKey(provider, recordType), delay, func() {
+			retryRecord(ctx, provider, state, recordType, ipAddress, timeout, attempt+1)
+		})
+	}
+	return syncOutcome{Result: result, Err: err}
 }
 
-func getPublicIPv6Address() (ipAddress string, err error) {
-	maxAttempts := 3
-	ipCheckServices := viper.GetStringSlice("ip_check.ipv6_urls")
-	rand.Seed(time.Now().Unix())
-	gotIP := false
-
-	for i := 0; i < maxAttempts && !gotIP; i++ {
-		victim := rand.Intn(len(ipCheckServices))
-		url := ipCheckServices[victim]
-		log.Infof("ipchk6: using '%s' for ip check", url)
-
-		response, herr := http.Get(url)
-		if herr != nil {
-			log.Errorf("ipchk6: Failed to get ip from '%s'", url)
-			log.Errorf("ipchk6: err=%s", herr)
-			continue
-		}
-		defer response.Body.Close()
-		body, berr := ioutil.ReadAll(response.Body)
-		if berr != nil {
-			log.Errorf("ipchk6: Could not read response from '%s'", url)
-			log.Errorf("ipchk6: err=%s", berr)
-			continue
-		}
-		ipAddress = strings.TrimSpace(string(body))
-		if ip := net.ParseIP(ipAddress); ip == nil || ip.To16() == nil {
-			log.Errorf("ipchk6: response is not a valid IPv6 address. response='%s'",
-				ipAddress)
-			ipAddress = ""
-			continue
+// retryRecord runs a scheduled retry, waiting for any running sync to finish
+// first so a provider is never synced twice at the same time. The retry is
+// dropped if a later sync detected a different address meanwhile
+func retryRecord(ctx context.Context, provider dns.Provider, state *syncState,
+	recordType string, ipAddress string, timeout time.Duration, attempt int) {
+	syncCycle <- struct{}{}
+	defer func() { <-syncCycle }()
+
+	if state.Get(provider, recordType) == ipAddress {
+		return
+	}
+	if latest := status.Address(recordType); latest != "" && latest != ipAddress {
+		log.Debugf("sync: %s record=%s type=%s dropping retry of ip=%s, the address is now %s",
+			provider.GetName(), provider.GetRecord(), recordType, ipAddress, latest)
+		return
+	}
+	if outcome := syncRecord(ctx, provider, state, recordType, ipAddress, timeout, attempt); outcome.Err == nil {
+		if err := state.Save(); err != nil {
+			log.Errorf("sync: could not save state file err=%s", err)
 		}
-		gotIP = true
 	}
-	if !gotIP {
-		err = fmt.Errorf("ipchk6: ran out of attempts to get IP address")
+}
+
+// logResult reports what a provider sync did to its record
+func logResult(result dns.Result) {
+	switch result.Action {
+	case dns.Unchanged:
+		log.Infof("sync: %s record=%s type=%s unchanged ip=%s",
+			result.Provider, result.Record, result.RecordType, result.NewValue)
+	case dns.Created:
+		log.Infof("sync: %s record=%s type=%s created ip=%s id=%s",
+			result.Provider, result.Record, result.RecordType, result.NewValue, result.RecordID)
+	default:
+		log.Infof("sync: %s record=%s type=%s %s ip=%s->%s",
+			result.Provider, result.Record, result.RecordType, result.Action,
+			result.OldValue, result.NewValue)
 	}
+}
+
+func getPublicIPv4Address() (ipAddress string, err error) {
+	return getPublicIPAddress(ipcheck.IPv4)
+}
+
+func getPublicIPv6Address() (ipAddress string, err error) {
+	return getPublicIPAddress(ipcheck.IPv6)
+}
 
-	log.Infof("ipchk6: got public IP address=%s", ipAddress)
-	return ipAddress, err
+func getPublicIPAddress(family ipcheck.Family) (ipAddress string, err error) {
+	sources, err := getIPSources(family)
+	if err != nil {
+		return "", err
+	}
+	quorumSize := viper.GetInt("ip_check.quorum.sources")
+	if quorumSize > 0 {
+		return ipcheck.QuorumLookup(sources, family, quorumSize, viper.GetInt("ip_check.quorum.min_agree"))
+	}
+	return ipcheck.Lookup(sources, family, 3)
 }
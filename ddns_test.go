@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncRecordsParallel(t *testing.T) {
+	viper.Set("service.workers", 4)
+	defer viper.Set("service.workers", nil)
+
+	state, _ := loadState("")
+	providers := dnsProvidersList{}
+	fakes := []*fakeProvider{}
+	for i := 0; i < 4; i++ {
+		provider := &fakeProvider{record: "home.domain.com", delay: 100 * time.Millisecond,
+			updates: map[string]string{}}
+		fakes = append(fakes, provider)
+		providers = append(providers, provider)
+	}
+
+	start := time.Now()
+	assert.False(t, anyFailed(syncRecords(context.Background(), providers, state, "A", "192.0.2.1", true)))
+	assert.True(t, time.Since(start) < 300*time.Millisecond, "providers should sync in parallel")
+	for _, provider := range fakes {
+		assert.Equal(t, "192.0.2.1", provider.updates["A"])
+	}
+}
+
+func TestSyncRecordsTimeout(t *testing.T) {
+	viper.Set("service.provider_timeout", "10ms")
+	defer viper.Set("service.provider_timeout", nil)
+
+	state, _ := loadState("")
+	slow := &fakeProvider{record: "slow.domain.com", delay: time.Second, updates: map[string]string{}}
+	fast := &fakeProvider{record: "fast.domain.com", updates: map[string]string{}}
+
+	start := time.Now()
+	assert.True(t, anyFailed(syncRecords(context.Background(), dnsProvidersList{slow, fast}, state, "A", "192.0.2.1", false)))
+	assert.True(t, time.Since(start) < 500*time.Millisecond, "slow provider should time out")
+	assert.Equal(t, "192.0.2.1", fast.updates["A"])
+	assert.Equal(t, "", state.Get(slow, "A"))
+}
+
+func TestTrySyncDomainSkipsOverlap(t *testing.T) {
+	syncCycle <- struct{}{}
+	state, _ := loadState("")
+	assert.False(t, trySyncDomain(context.Background(), dnsProvidersList{}, state))
+	<-syncCycle
+}
+
+func TestRunServiceShutdown(t *testing.T) {
+	defer func() { retries = newRetryScheduler() }()
+	defer func() { syncs = newSyncGroup() }()
+	viper.Set("ip_check.ipv4", false)
+	viper.Set("ip_check.ipv6", false)
+	defer viper.Set("ip_check.ipv4", nil)
+	defer viper.Set("ip_check.ipv6", nil)
+
+	dir, err := ioutil.TempDir("", "dyngo")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	state, _ := loadState(filepath.Join(dir, "state.json"))
+	state.Set(&fakeProvider{record: "home.domain.com"}, "A", "192.0.2.1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunService(ctx, newConfigReloader(dnsProvidersList{}), state, time.Hour, time.Second)
+		close(done)
+	}()
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("service did not stop")
+	}
+	_, err = os.Stat(filepath.Join(dir, "state.json"))
+	assert.NoError(t, err, "state should be saved on shutdown")
+
+	// requested syncs are refused once the service stopped
+	_, err = SyncNow(context.Background(), dnsProvidersList{}, state)
+	assert.Error(t, err)
+}
+
+func TestSyncGroup(t *testing.T) {
+	g := newSyncGroup()
+	started := make(chan struct{})
+	g.Go(func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+	})
+	<-started
+
+	g.Stop()
+	assert.False(t, g.Run(func(ctx context.Context) {}), "stopped groups refuse new syncs")
+	assert.False(t, waitFor(50*time.Millisecond, g.Wait), "running syncs are waited on")
+	g.Cancel()
+	assert.True(t, waitFor(time.Second, g.Wait))
+}
+
+func TestWaitFor(t *testing.T) {
+	assert.True(t, waitFor(time.Second, func() {}, func() {}))
+	assert.False(t, waitFor(10*time.Millisecond, func() { time.Sleep(time.Second) }))
+}
@@ -1,7 +1,9 @@
 package dns
 
 import (
+	"context"
 	"errors"
+	"strings"
 
 	"github.com/cloudflare/cloudflare-go"
 	"github.com/sirupsen/logrus"
@@ -42,24 +44,82 @@ func (c *CloudflareDNS) GetName() Name {
 	return c.name
 }
 
-// SyncARecord sets an A record to the given IPv4 address
-func (c *CloudflareDNS) SyncARecord(ipv4Address string) error {
-	return c.SyncRecord("A", ipv4Address)
+// GetRecord returns the record being synced
+func (c *CloudflareDNS) GetRecord() string {
+	return c.record
 }
 
-// SyncAAAARecord sets an AAAA record to the given IPv6 address
-func (c *CloudflareDNS) SyncAAAARecord(ipv6Address string) error {
-	return c.SyncRecord("AAAA", ipv6Address)
+// SyncRecord sets the given record to match ipAddress
+func (c *CloudflareDNS) SyncRecord(ctx context.Context, recordType string, ipAddress string) (Result, error) {
+	result := NewResult(c, recordType, ipAddress)
+	zoneID, records, err := c.findRecords(ctx, recordType)
+	if err != nil {
+		return result, err
+	}
+	current, err := c.currentRecord(records)
+	if err != nil {
+		return result, err
+	}
+	result = PlanRecord(c, recordType, ipAddress, current)
+
+	switch result.Action {
+	case Unchanged:
+		c.log.Infof("cfl: record does not need to be updated")
+		return result, nil
+	case Created:
+		c.log.Infof("cfl: no matching record found, will attempt to create")
+		res, err := c.createDomainRecord(zoneID, recordType, ipAddress)
+		if err != nil {
+			c.log.WithFields(logrus.Fields{
+				"domain": c.record,
+				"err":    err,
+			}).Errorf("cfl: could not create a new domain record")
+			return result, cloudflareError(err)
+		}
+		c.log.Infof("cfl: new record suceessfully created")
+		result.RecordID = res.Result.ID
+		return result, nil
+	}
+
+	// Else, we need to update the domain record
+	record := records[0]
+	c.log.WithFields(logrus.Fields{
+		"id": record.ID,
+		"ip": record.Content,
+	}).Infof("cfl: updating record")
+	record.Content = ipAddress
+	err = c.api.UpdateDNSRecord(zoneID, record.ID, record)
+	if err != nil {
+		c.log.WithFields(logrus.Fields{
+			"domain": c.record,
+			"id":     record.ID,
+			"err":    err,
+		}).Errorf("cfl: could not update domain record")
+		return result, cloudflareError(err)
+	}
+	c.log.Infof("cfl: record successfully updated")
+
+	return result, nil
 }
 
-// SyncRecord sets the given record to match ipAddress
-func (c *CloudflareDNS) SyncRecord(recordType string, ipAddress string) error {
+// CurrentRecord returns the record as it is set at Cloudflare
+func (c *CloudflareDNS) CurrentRecord(ctx context.Context, recordType string) (Record, error) {
+	_, records, err := c.findRecords(ctx, recordType)
+	if err != nil {
+		return Record{}, err
+	}
+	return c.currentRecord(records)
+}
+
+// findRecords logs in and returns the zone of the record and the records
+// that match it
+func (c *CloudflareDNS) findRecords(ctx context.Context, recordType string) (string, []cloudflare.DNSRecord, error) {
 	// Authenticate with Cloudflare
 	var err error
-	c.api, err = cloudflare.NewWithAPIToken(c.token)
+	c.api, err = cloudflare.NewWithAPIToken(c.token, cloudflare.HTTPClient(contextClient(ctx)))
 	if err != nil {
 		c.log.Errorf("cfl: could not log in: %v", err)
-		return err
+		return "", nil, Permanent(err)
 	}
 	domainName, recordName := SplitDomainRecord(c.record)
 	c.log.Debugf("cfl: searching for domain=%s record=%s", domainName, recordName)
@@ -71,7 +131,7 @@ func (c *CloudflareDNS) SyncRecord(recordType string, ipAddress string) error {
 			"domain": domainName,
 			"err":    err,
 		}).Errorf("cfl: could not find the domain")
-		return err
+		return "", nil, cloudflareError(err)
 	}
 	records, err := c.api.DNSRecords(zoneID, cloudflare.DNSRecord{
 		Type: recordType,
@@ -81,55 +141,29 @@ func (c *CloudflareDNS) SyncRecord(recordType string, ipAddress string) error {
 		c.log.WithFields(logrus.Fields{
 			"err": err,
 		}).Errorf("cfl: could not get a list of records")
-		return err
+		return "", nil, cloudflareError(err)
 	}
 	c.log.Debugf("cfl: %d matching records found", len(records))
+	return zoneID, records, nil
+}
+
+// currentRecord returns the current record from the matching records,
+// round robin record sets are not supported
+func (c *CloudflareDNS) currentRecord(records []cloudflare.DNSRecord) (Record, error) {
 	if len(records) > 1 {
 		c.log.Errorf("cfl: Found %d matching records, will not update a round robin record set", len(records))
-		return errors.New("Found more then one matching record")
-	} else if len(records) == 0 {
-		c.log.Infof("cfl: no matching record found, will attempt to create")
-		_, err := c.createDomainRecord(zoneID, recordType, ipAddress)
-		if err != nil {
-			c.log.WithFields(logrus.Fields{
-				"domain": domainName,
-				"err":    err,
-			}).Errorf("cfl: could not create a new domain record")
-			return err
-		}
-		c.log.Infof("cfl: new record suceessfully created")
-		return nil
-	}
-
-	// We have one matching record, check if we need to update
-	record := records[0]
-	c.log.WithFields(logrus.Fields{
-		"id": record.ID,
-		"ip": record.Content,
-	}).Debugf("cfl: found matching record")
-	if ipAddress == records[0].Content {
-		c.log.Infof("cfl: record does not need to be updated")
-		return nil
+		return Record{}, errRoundRobin
 	}
-
-	// Else, we need to update the domain record
-	c.log.WithFields(logrus.Fields{
-		"id": record.ID,
-		"ip": record.Content,
-	}).Infof("cfl: updating record")
-	record.Content = ipAddress
-	err = c.api.UpdateDNSRecord(zoneID, record.ID, record)
-	if err != nil {
+	current := Record{}
+	if len(records) == 1 {
 		c.log.WithFields(logrus.Fields{
-			"domain": c.record,
-			"id":     record.ID,
-			"err":    err,
-		}).Errorf("cfl: could not update domain record")
-		return err
+			"id": records[0].ID,
+			"ip": records[0].Content,
+		}).Debugf("cfl: found matching record")
+		current.ID = records[0].ID
+		current.Values = []string{records[0].Content}
 	}
-	c.log.Infof("cfl: record successfully updated")
-
-	return nil
+	return current, nil
 }
 
 func (c *CloudflareDNS) createDomainRecord(zoneID string, recordType string, ipAddress string) (*cloudflare.DNSRecordResponse, error) {
@@ -144,3 +178,14 @@ func (c *CloudflareDNS) createDomainRecord(zoneID string, recordType string, ipA
 	return res, err
 
 }
+
+// cloudflareError marks the errors retrying will not fix as permanent
+func cloudflareError(err error) error {
+	message := err.Error()
+	if strings.Contains(message, "invalid credentials") ||
+		strings.Contains(message, "insufficient permissions") ||
+		strings.Contains(message, "Zone could not be found") {
+		return Permanent(err)
+	}
+	return err
+}
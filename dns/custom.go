@@ -2,6 +2,7 @@ package dns
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"os/exec"
 	"strings"
@@ -45,20 +46,21 @@ func (c *CustomScriptDNS) GetName() Name {
 	return c.name
 }
 
-// SyncARecord sets an A record to the given IPv4 address
-func (c *CustomScriptDNS) SyncARecord(ipv4Address string) error {
-	return c.SyncRecord("A", ipv4Address)
+// GetRecord returns the record being synced
+func (c *CustomScriptDNS) GetRecord() string {
+	return c.record
 }
 
-// SyncAAAARecord sets an AAAA record to the given IPv6 address
-func (c *CustomScriptDNS) SyncAAAARecord(ipv6Address string) error {
-	return c.SyncRecord("AAAA", ipv6Address)
+// CurrentRecord returns an unknown record, the script can only set it
+func (c *CustomScriptDNS) CurrentRecord(ctx context.Context, recordType string) (Record, error) {
+	return Record{Unknown: true}, nil
 }
 
 // SyncRecord sets the given record to match ipAddress
-func (c *CustomScriptDNS) SyncRecord(recordType string, ipAddress string) error {
+func (c *CustomScriptDNS) SyncRecord(ctx context.Context, recordType string, ipAddress string) (Result, error) {
+	result := NewResult(c, recordType, ipAddress)
 	// Run the script
-	cmd := exec.Command(c.path, c.record, recordType, ipAddress, c.args)
+	cmd := exec.CommandContext(ctx, c.path, c.record, recordType, ipAddress, c.args)
 	log.Debugf("cus: running cmd %v", cmd.Args)
 	var out bytes.Buffer
 	cmd.Stderr = &out
@@ -69,8 +71,14 @@ func (c *CustomScriptDNS) SyncRecord(recordType string, ipAddress string) error
 			log.Errorf("stderr: %s", strings.TrimSpace(out.String()))
 		}
 		log.Error(err)
-		return err
+		// a script that can not be started will not start on a retry
+		if _, ok := err.(*exec.ExitError); !ok && ctx.Err() == nil {
+			return result, Permanent(err)
+		}
+		return result, err
 	}
+	// the script does not tell us if it changed the record
+	result.Action = Synced
 
-	return nil
+	return result, nil
 }
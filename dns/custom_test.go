@@ -0,0 +1,22 @@
+package dns
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomScriptSync(t *testing.T) {
+	provider, err := NewCustomScriptDNS(ProviderConfig{"path": "/bin/true", "record": "sub.domain.com"})
+	assert.NoError(t, err)
+	result, err := provider.SyncRecord(context.Background(), "A", "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, Synced, result.Action, "the script can not tell if the record changed")
+
+	provider, err = NewCustomScriptDNS(ProviderConfig{"path": "/bin/false", "record": "sub.domain.com"})
+	assert.NoError(t, err)
+	_, err = provider.SyncRecord(context.Background(), "A", "10.0.0.1")
+	assert.Error(t, err)
+	assert.False(t, IsPermanent(err))
+}
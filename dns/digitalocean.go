@@ -3,6 +3,7 @@ package dns
 import (
 	"context"
 	"errors"
+	"strconv"
 
 	"github.com/digitalocean/godo"
 	"github.com/sirupsen/logrus"
@@ -43,20 +44,69 @@ func (d *DigitalOceanDNS) GetName() Name {
 	return d.name
 }
 
-// SyncARecord sets an A record to the given IPv4 address
-func (d *DigitalOceanDNS) SyncARecord(ipv4Address string) error {
-	return d.SyncRecord("A", ipv4Address)
+// GetRecord returns the record being synced
+func (d *DigitalOceanDNS) GetRecord() string {
+	return d.record
 }
 
-// SyncAAAARecord sets an AAAA record to the given IPv6 address
-func (d *DigitalOceanDNS) SyncAAAARecord(ipv6Address string) error {
-	return d.SyncRecord("AAAA", ipv6Address)
+// SyncRecord sets the given record to match ipAddress
+func (d *DigitalOceanDNS) SyncRecord(ctx context.Context, recordType string, ipAddress string) (Result, error) {
+	result := NewResult(d, recordType, ipAddress)
+	record, err := d.findRecord(ctx, recordType)
+	if err != nil {
+		return result, err
+	}
+	result = PlanRecord(d, recordType, ipAddress, digitalOceanRecord(record))
+	domainName, recordName := SplitDomainRecord(d.record)
+
+	switch result.Action {
+	case Unchanged:
+		d.log.Infof("do: record does not need to be updated")
+		return result, nil
+	case Created:
+		d.log.Infof("do: no matching record found, will attempt to create")
+		record, err := d.createDomainRecord(domainName, recordName, recordType, ipAddress)
+		if err != nil {
+			d.log.Errorf("do: could not create a new domain record")
+			d.log.Errorf("do: err=%s", err)
+			return result, digitalOceanError(err)
+		}
+		d.log.Infof("do: new record successfully created")
+		result.RecordID = strconv.Itoa(record.ID)
+		return result, nil
+	}
+
+	// Else, we need to update the domain record
+	editRequest := &godo.DomainRecordEditRequest{
+		Type: recordType,
+		Data: ipAddress,
+	}
+	_, _, err = d.auth.Client.Domains.EditRecord(d.auth.Ctx, domainName, record.ID, editRequest)
+	if err != nil {
+		d.log.Errorf("do: could not update domain record domain=%s id=%d",
+			domainName, record.ID)
+		d.log.Errorf("do: err=%s", err)
+		return result, digitalOceanError(err)
+	}
+	d.log.Infof("do: record successfully updated")
+
+	return result, nil
 }
 
-// SyncRecord sets the given record to match ipAddress
-func (d *DigitalOceanDNS) SyncRecord(recordType string, ipAddress string) error {
+// CurrentRecord returns the record as it is set at DigitalOcean
+func (d *DigitalOceanDNS) CurrentRecord(ctx context.Context, recordType string) (Record, error) {
+	record, err := d.findRecord(ctx, recordType)
+	if err != nil {
+		return Record{}, err
+	}
+	return digitalOceanRecord(record), nil
+}
+
+// findRecord logs in and returns the domain record that matches ours, or
+// nil if there is none
+func (d *DigitalOceanDNS) findRecord(ctx context.Context, recordType string) (*godo.DomainRecord, error) {
 	// Authenticate with DigitalOcean
-	d.auth = newDoAuth(d.token)
+	d.auth = newDoAuth(ctx, d.token)
 	domainName, recordName := SplitDomainRecord(d.record)
 	d.log.Debugf("do: searching for domain=%s record=%s", domainName, recordName)
 
@@ -65,54 +115,29 @@ func (d *DigitalOceanDNS) SyncRecord(recordType string, ipAddress string) error
 	if err != nil {
 		d.log.Errorf("do: could not get list of domain records")
 		d.log.Errorf("do: err=%s", err)
-		return err
+		return nil, digitalOceanError(err)
 	}
 
 	// Now we need to find which domain record matches ours
 	d.log.Debugf("do: %d records found", len(records))
-	matchingIdx := -1
 	for idx, record := range records {
 		if record.Type == recordType {
 			d.log.Debugf("do: record=%s", record)
 			if record.Name == recordName {
-				matchingIdx = idx
-				break
+				d.log.Debugf("do: found matching record id=%d ip=%s", record.ID, record.Data)
+				return &records[idx], nil
 			}
 		}
 	}
-	if matchingIdx < 0 {
-		d.log.Infof("do: no matching record found, will attempt to create")
-		_, err = d.createDomainRecord(domainName, recordName, recordType, ipAddress)
-		if err != nil {
-			d.log.Errorf("do: could not create a new domain record")
-			d.log.Errorf("do: err=%s", err)
-			return err
-		}
-		d.log.Infof("do: new record successfully created")
-		return nil
-	}
-	d.log.Debugf("do: found matching record id=%d ip=%s",
-		records[matchingIdx].ID, records[matchingIdx].Data)
-	if ipAddress == records[matchingIdx].Data {
-		d.log.Infof("do: record does not need to be updated")
-		return nil
-	}
+	return nil, nil
+}
 
-	// Else, we need to update the domain record
-	editRequest := &godo.DomainRecordEditRequest{
-		Type: recordType,
-		Data: ipAddress,
+// digitalOceanRecord returns the current record of a domain record
+func digitalOceanRecord(record *godo.DomainRecord) Record {
+	if record == nil {
+		return Record{}
 	}
-	_, _, err = d.auth.Client.Domains.EditRecord(d.auth.Ctx, domainName, records[matchingIdx].ID, editRequest)
-	if err != nil {
-		d.log.Errorf("do: could not update domain record domain=%s id=%d",
-			domainName, records[matchingIdx].ID)
-		d.log.Errorf("do: err=%s", err)
-		return err
-	}
-	d.log.Infof("do: record successfully updated")
-
-	return nil
+	return Record{ID: strconv.Itoa(record.ID), Values: []string{record.Data}}
 }
 
 func (d *DigitalOceanDNS) getDomainRecords(domain string) ([]godo.DomainRecord, error) {
@@ -155,7 +180,7 @@ type doAuth struct {
 	Ctx    context.Context
 }
 
-func newDoAuth(apiToken string) doAuth {
+func newDoAuth(ctx context.Context, apiToken string) doAuth {
 	token := &doTokenSource{
 		AccessToken: apiToken,
 	}
@@ -164,7 +189,15 @@ func newDoAuth(apiToken string) doAuth {
 
 	var auth = doAuth{
 		Client: godo.NewClient(oauthClient),
-		Ctx:    context.TODO(),
+		Ctx:    ctx,
 	}
 	return auth
 }
+
+// digitalOceanError marks the errors retrying will not fix as permanent
+func digitalOceanError(err error) error {
+	if res, ok := err.(*godo.ErrorResponse); ok && res.Response != nil {
+		return permanentStatus(res.Response.StatusCode, err)
+	}
+	return err
+}
@@ -1,6 +1,7 @@
 package dns
 
 import (
+	"context"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -17,13 +18,137 @@ type Name string
 // ProviderConfig is the generic config format
 type ProviderConfig map[string]string
 
+// Action is what a sync did to a record
+type Action string
+
+// Sync actions
+const (
+	Unchanged Action = "unchanged"
+	Updated   Action = "updated"
+	Created   Action = "created"
+	// Synced is used when a provider can not tell if the record changed
+	Synced Action = "synced"
+)
+
+// Result is the outcome of syncing a record
+type Result struct {
+	Provider   Name
+	Record     string
+	RecordType string
+	RecordID   string
+	Action     Action
+	OldValue   string
+	NewValue   string
+}
+
+// Record is the current state of a record at a provider
+type Record struct {
+	ID     string
+	Values []string
+	// Unknown is set when the provider can not read the record, syncing it
+	// always sends the address
+	Unknown bool
+}
+
 // Provider generic interface
 type Provider interface {
+	SyncRecord(ctx context.Context, recordType string, ipAddress string) (Result, error)
+	// CurrentRecord reads the record from the provider without changing it
+	CurrentRecord(ctx context.Context, recordType string) (Record, error)
+	GetName() Name
+	GetRecord() string
+}
+
+// LegacyProvider is the interface providers implemented before sync
+// results and contexts, use AdaptLegacyProvider to turn it into a Provider
+type LegacyProvider interface {
 	SyncARecord(ipv4Address string) error
 	SyncAAAARecord(ipv6Address string) error
 	GetName() Name
 }
 
+// legacyAdapter runs a LegacyProvider as a Provider
+type legacyAdapter struct {
+	LegacyProvider
+	record string
+}
+
+// AdaptLegacyProvider wraps a LegacyProvider that syncs record so it can be
+// used as a Provider, results always have the Synced action
+func AdaptLegacyProvider(provider LegacyProvider, record string) Provider {
+	return &legacyAdapter{provider, record}
+}
+
+// GetRecord returns the record being synced
+func (a *legacyAdapter) GetRecord() string {
+	return a.record
+}
+
+// SyncRecord runs the legacy sync, and stops waiting on it when ctx is done.
+// The legacy call can not be canceled, so it keeps running in the
+// background until it returns
+func (a *legacyAdapter) SyncRecord(ctx context.Context, recordType string, ipAddress string) (Result, error) {
+	result := NewResult(a, recordType, ipAddress)
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		switch recordType {
+		case "A":
+			done <- a.SyncARecord(ipAddress)
+		case "AAAA":
+			done <- a.SyncAAAARecord(ipAddress)
+		default:
+			done <- errors.Errorf("record type '%s' not supported", recordType)
+		}
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			result.Action = Synced
+		}
+		return result, err
+	case <-ctx.Done():
+		return result, ctx.Err()
+	}
+}
+
+// CurrentRecord returns an unknown record, legacy providers can not read it
+func (a *legacyAdapter) CurrentRecord(ctx context.Context, recordType string) (Record, error) {
+	return Record{Unknown: true}, ctx.Err()
+}
+
+// NewResult returns a result for syncing the providers record to ipAddress
+func NewResult(provider Provider, recordType string, ipAddress string) Result {
+	return Result{
+		Provider:   provider.GetName(),
+		Record:     provider.GetRecord(),
+		RecordType: recordType,
+		NewValue:   ipAddress,
+	}
+}
+
+// PlanRecord returns the result syncing the providers record from current to
+// ipAddress would have
+func PlanRecord(provider Provider, recordType string, ipAddress string, current Record) Result {
+	result := NewResult(provider, recordType, ipAddress)
+	result.RecordID = current.ID
+	result.OldValue = strings.Join(current.Values, ",")
+	switch {
+	case current.Unknown:
+		result.Action = Synced
+	case len(current.Values) == 0:
+		result.Action = Created
+	case len(current.Values) == 1 && current.Values[0] == ipAddress:
+		result.Action = Unchanged
+	default:
+		result.Action = Updated
+	}
+	return result
+}
+
 // GetDNSProvider returns a provider from a given config
 func GetDNSProvider(config ProviderConfig) (dns Provider, err error) {
 	name, ok := config["name"]
@@ -39,6 +164,12 @@ func GetDNSProvider(config ProviderConfig) (dns Provider, err error) {
 		dns, err = NewDigitalOceanDNS(config)
 	case customScriptName:
 		dns, err = NewCustomScriptDNS(config)
+	case route53Name:
+		dns, err = NewRoute53DNS(config)
+	case rfc2136Name:
+		dns, err = NewRFC2136DNS(config)
+	case dynDNS2Name:
+		dns, err = NewDynDNS2(config)
 	default:
 		err = errors.Errorf("dns provider name '%s' not recognized", cleanName)
 	}
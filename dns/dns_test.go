@@ -0,0 +1,94 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeLegacyProvider struct {
+	delay   time.Duration
+	fail    bool
+	updates map[string]string
+}
+
+func (f *fakeLegacyProvider) SyncARecord(ipv4Address string) error {
+	return f.sync("A", ipv4Address)
+}
+
+func (f *fakeLegacyProvider) SyncAAAARecord(ipv6Address string) error {
+	return f.sync("AAAA", ipv6Address)
+}
+
+func (f *fakeLegacyProvider) sync(recordType string, ipAddress string) error {
+	time.Sleep(f.delay)
+	if f.fail {
+		return errors.New("fake failure")
+	}
+	f.updates[recordType] = ipAddress
+	return nil
+}
+
+func (f *fakeLegacyProvider) GetName() Name {
+	return "legacy"
+}
+
+func TestAdaptLegacyProvider(t *testing.T) {
+	legacy := &fakeLegacyProvider{updates: map[string]string{}}
+	provider := AdaptLegacyProvider(legacy, "sub.domain.com")
+
+	result, err := provider.SyncRecord(context.Background(), "AAAA", "2001:db8::1")
+	assert.NoError(t, err)
+	assert.Equal(t, Result{
+		Provider:   "legacy",
+		Record:     "sub.domain.com",
+		RecordType: "AAAA",
+		Action:     Synced,
+		NewValue:   "2001:db8::1",
+	}, result)
+	assert.Equal(t, "2001:db8::1", legacy.updates["AAAA"])
+
+	_, err = provider.SyncRecord(context.Background(), "MX", "10.0.0.1")
+	assert.Error(t, err)
+
+	legacy.fail = true
+	result, err = provider.SyncRecord(context.Background(), "A", "10.0.0.1")
+	assert.Error(t, err)
+	assert.Empty(t, result.Action)
+}
+
+func TestAdaptLegacyProviderCancel(t *testing.T) {
+	provider := AdaptLegacyProvider(&fakeLegacyProvider{
+		delay:   time.Second,
+		updates: map[string]string{},
+	}, "sub.domain.com")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := provider.SyncRecord(ctx, "A", "10.0.0.1")
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestPlanRecord(t *testing.T) {
+	provider := AdaptLegacyProvider(&fakeLegacyProvider{}, "sub.domain.com")
+
+	result := PlanRecord(provider, "A", "10.0.0.1", Record{})
+	assert.Equal(t, Created, result.Action)
+	assert.Equal(t, Name("legacy"), result.Provider)
+	assert.Equal(t, "sub.domain.com", result.Record)
+
+	result = PlanRecord(provider, "A", "10.0.0.1", Record{ID: "42", Values: []string{"10.0.0.1"}})
+	assert.Equal(t, Unchanged, result.Action)
+	assert.Equal(t, "42", result.RecordID)
+
+	result = PlanRecord(provider, "A", "10.0.0.1", Record{Values: []string{"10.0.0.1", "10.0.0.2"}})
+	assert.Equal(t, Updated, result.Action)
+	assert.Equal(t, "10.0.0.1,10.0.0.2", result.OldValue)
+
+	current, err := provider.CurrentRecord(context.Background(), "A")
+	assert.NoError(t, err)
+	assert.Equal(t, Synced, PlanRecord(provider, "A", "10.0.0.1", current).Action)
+}
@@ -0,0 +1,222 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const dynDNS2Name = "dyndns2"
+
+// dynDNS2HoldOff is how long to wait after the server reports a failure
+const dynDNS2HoldOff = 30 * time.Minute
+
+// DynDNS2 response errors, these are all permanent since the protocol
+// does not allow clients to quickly retry after any of them
+var (
+	ErrDynDNS2BadAuth  = Permanent(errors.New("dyndns2: bad username or password"))
+	ErrDynDNS2NoHost   = Permanent(errors.New("dyndns2: hostname does not exist in this account"))
+	ErrDynDNS2NotFQDN  = Permanent(errors.New("dyndns2: hostname is not a fully qualified domain name"))
+	ErrDynDNS2BadAgent = Permanent(errors.New("dyndns2: user agent was blocked"))
+	ErrDynDNS2Donator  = Permanent(errors.New("dyndns2: feature not available to this account"))
+	ErrDynDNS2Abuse    = Permanent(errors.New("dyndns2: hostname was blocked for abuse"))
+	ErrDynDNS2DNSError = Permanent(errors.New("dyndns2: server dns error"))
+	ErrDynDNS2Failure  = Permanent(errors.New("dyndns2: server failure"))
+	ErrDynDNS2Blocked  = Permanent(errors.New("dyndns2: updates stopped after a previous error"))
+	ErrDynDNS2HoldOff  = Permanent(errors.New("dyndns2: updates on hold after a previous server failure"))
+)
+
+// dynDNS2Errors maps the response codes to errors
+var dynDNS2Errors = map[string]error{
+	"badauth":  ErrDynDNS2BadAuth,
+	"nohost":   ErrDynDNS2NoHost,
+	"notfqdn":  ErrDynDNS2NotFQDN,
+	"badagent": ErrDynDNS2BadAgent,
+	"!donator": ErrDynDNS2Donator,
+	"abuse":    ErrDynDNS2Abuse,
+	"dnserr":   ErrDynDNS2DNSError,
+	"911":      ErrDynDNS2Failure,
+}
+
+// DynDNS2 instance, blocked and holdUntil are only kept in memory so a
+// restart or config reload clears them
+type DynDNS2 struct {
+	name      Name
+	server    string
+	username  string
+	password  string
+	record    string
+	client    *http.Client
+	lastIP    map[string]string
+	blocked   error
+	holdUntil time.Time
+	log       *logrus.Entry
+}
+
+// NewDynDNS2 is DynDNS2 constructor
+func NewDynDNS2(config ProviderConfig) (*DynDNS2, error) {
+	d := &DynDNS2{}
+	d.name = dynDNS2Name
+	var ok bool
+	d.server, ok = config["server"]
+	if !ok {
+		return d, errors.New("server missing from DynDNS2 provider")
+	}
+	serverURL, err := url.Parse(d.server)
+	if err != nil || serverURL.Host == "" {
+		return d, errors.New("server is not a valid url in DynDNS2 provider")
+	}
+	if serverURL.Path == "" || serverURL.Path == "/" {
+		serverURL.Path = "/nic/update"
+	}
+	d.server = serverURL.String()
+	d.username, ok = config["username"]
+	if !ok {
+		return d, errors.New("username missing from DynDNS2 provider")
+	}
+	d.password, ok = config["password"]
+	if !ok {
+		return d, errors.New("password missing from DynDNS2 provider")
+	}
+	d.record, ok = config["record"]
+	if !ok {
+		return d, errors.New("record missing from DynDNS2 provider")
+	}
+	d.client = &http.Client{Timeout: 30 * time.Second}
+	d.lastIP = map[string]string{}
+
+	d.log = log.WithFields(logrus.Fields{"dns": "dyn"})
+	return d, nil
+}
+
+// GetName returns name identifier
+func (d *DynDNS2) GetName() Name {
+	return d.name
+}
+
+// GetRecord returns the record being synced
+func (d *DynDNS2) GetRecord() string {
+	return d.record
+}
+
+// SyncRecord sets the given record to match ipAddress
+func (d *DynDNS2) SyncRecord(ctx context.Context, recordType string, ipAddress string) (Result, error) {
+	result := NewResult(d, recordType, ipAddress)
+	// The protocol asks clients to stop sending updates after some errors
+	if d.blocked != nil {
+		d.log.Errorf("dyn: not sending update, updates stopped after: %v", d.blocked)
+		return result, ErrDynDNS2Blocked
+	}
+	if time.Now().Before(d.holdUntil) {
+		d.log.Warnf("dyn: not sending update, on hold until %s", d.holdUntil.Format(time.RFC3339))
+		return result, ErrDynDNS2HoldOff
+	}
+	// There is no way to query the record, and repeated updates with the
+	// same address are treated as abuse by most services
+	result.OldValue = d.lastIP[recordType]
+	if d.lastIP[recordType] == ipAddress {
+		d.log.Infof("dyn: record does not need to be updated")
+		result.Action = Unchanged
+		return result, nil
+	}
+
+	d.log.Debugf("dyn: sending update for hostname=%s ip=%s", d.record, ipAddress)
+	code, err := d.sendUpdate(ctx, ipAddress)
+	if err != nil {
+		d.log.WithFields(logrus.Fields{
+			"server": d.server,
+			"err":    err,
+		}).Errorf("dyn: could not send update")
+		return result, err
+	}
+
+	switch code {
+	case "good":
+		d.lastIP[recordType] = ipAddress
+		d.log.Infof("dyn: record successfully updated")
+		result.Action = Updated
+		return result, nil
+	case "nochg":
+		d.lastIP[recordType] = ipAddress
+		d.log.Infof("dyn: record does not need to be updated")
+		result.Action = Unchanged
+		return result, nil
+	}
+
+	// unknown codes are held like server failures, only the codes that
+	// ask clients to stop stop the updates
+	err, known := dynDNS2Errors[code]
+	if !known {
+		err = Permanent(fmt.Errorf("dyndns2: unknown response '%s'", code))
+	}
+	if !known || err == ErrDynDNS2Failure || err == ErrDynDNS2DNSError {
+		d.holdUntil = time.Now().Add(dynDNS2HoldOff)
+		d.log.WithFields(logrus.Fields{
+			"code": code,
+		}).Errorf("dyn: server failure, holding updates for %s", dynDNS2HoldOff)
+	} else {
+		d.blocked = err
+		d.log.WithFields(logrus.Fields{
+			"code": code,
+		}).Errorf("dyn: update rejected, stopping further updates: %v", err)
+	}
+	return result, err
+}
+
+// CurrentRecord returns the address last sent, the protocol has no way to
+// query the record so it is unknown until an update was sent
+func (d *DynDNS2) CurrentRecord(ctx context.Context, recordType string) (Record, error) {
+	if d.blocked != nil {
+		return Record{}, ErrDynDNS2Blocked
+	}
+	lastIP, ok := d.lastIP[recordType]
+	if !ok {
+		return Record{Unknown: true}, nil
+	}
+	return Record{Values: []string{lastIP}}, nil
+}
+
+// sendUpdate sends the update request and returns the response code
+func (d *DynDNS2) sendUpdate(ctx context.Context, ipAddress string) (string, error) {
+	params := url.Values{}
+	params.Set("hostname", d.record)
+	params.Set("myip", ipAddress)
+	req, err := http.NewRequest(http.MethodGet, d.server+"?"+params.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	req.SetBasicAuth(d.username, d.password)
+	req.Header.Set("User-Agent", "gesquive-dyngo/"+libVersion)
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode == http.StatusUnauthorized {
+		return "badauth", nil
+	}
+	// ie. an error page from a proxy in front of the server
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return "", fmt.Errorf("server returned status %d", res.StatusCode)
+	}
+
+	// responses are '<code> [ip address]'
+	fields := strings.Fields(string(body))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty response with status %d", res.StatusCode)
+	}
+	return fields[0], nil
+}
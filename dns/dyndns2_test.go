@@ -0,0 +1,140 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDynDNS2(t *testing.T, responses ...string) (*DynDNS2, *int, func()) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "user", user)
+		assert.Equal(t, "pass", pass)
+		assert.Equal(t, "/nic/update", r.URL.Path)
+		assert.Equal(t, "sub.domain.com", r.URL.Query().Get("hostname"))
+		fmt.Fprintf(w, "%s %s\n", responses[requests], r.URL.Query().Get("myip"))
+		requests++
+	}))
+	provider, err := NewDynDNS2(ProviderConfig{
+		"server":   server.URL,
+		"username": "user",
+		"password": "pass",
+		"record":   "sub.domain.com",
+	})
+	assert.NoError(t, err)
+	return provider, &requests, server.Close
+}
+
+func TestDynDNS2Config(t *testing.T) {
+	_, err := NewDynDNS2(ProviderConfig{"server": "not a url", "username": "u",
+		"password": "p", "record": "sub.domain.com"})
+	assert.Error(t, err)
+
+	provider, err := NewDynDNS2(ProviderConfig{"server": "https://dynupdate.no-ip.com",
+		"username": "u", "password": "p", "record": "sub.domain.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://dynupdate.no-ip.com/nic/update", provider.server)
+}
+
+func TestDynDNS2Good(t *testing.T) {
+	provider, requests, stop := newTestDynDNS2(t, "good", "nochg")
+	defer stop()
+
+	result, err := provider.SyncRecord(context.Background(), "A", "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, Updated, result.Action)
+	result, err = provider.SyncRecord(context.Background(), "A", "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, Unchanged, result.Action)
+	assert.Equal(t, 1, *requests, "same address should not be sent twice")
+
+	result, err = provider.SyncRecord(context.Background(), "AAAA", "2001:db8::1")
+	assert.NoError(t, err)
+	assert.Equal(t, Unchanged, result.Action, "nochg response")
+	assert.Equal(t, 2, *requests)
+}
+
+func TestDynDNS2CurrentRecord(t *testing.T) {
+	provider, requests, stop := newTestDynDNS2(t, "good")
+	defer stop()
+
+	current, err := provider.CurrentRecord(context.Background(), "A")
+	assert.NoError(t, err)
+	assert.True(t, current.Unknown, "nothing sent yet")
+
+	_, err = provider.SyncRecord(context.Background(), "A", "10.0.0.1")
+	assert.NoError(t, err)
+	current, err = provider.CurrentRecord(context.Background(), "A")
+	assert.NoError(t, err)
+	assert.Equal(t, Record{Values: []string{"10.0.0.1"}}, current)
+	assert.Equal(t, 1, *requests)
+}
+
+func TestDynDNS2Errors(t *testing.T) {
+	for code, expected := range dynDNS2Errors {
+		provider, _, stop := newTestDynDNS2(t, code)
+		assert.Equal(t, expected, syncA(provider, "10.0.0.1"), code)
+		stop()
+	}
+}
+
+func TestDynDNS2AbuseStopsUpdates(t *testing.T) {
+	provider, requests, stop := newTestDynDNS2(t, "abuse")
+	defer stop()
+
+	assert.Equal(t, ErrDynDNS2Abuse, syncA(provider, "10.0.0.1"))
+	assert.Equal(t, ErrDynDNS2Blocked, syncA(provider, "10.0.0.2"))
+	assert.Equal(t, 1, *requests)
+}
+
+func TestDynDNS2FailureHoldsUpdates(t *testing.T) {
+	provider, requests, stop := newTestDynDNS2(t, "911")
+	defer stop()
+
+	assert.Equal(t, ErrDynDNS2Failure, syncA(provider, "10.0.0.1"))
+	assert.Equal(t, ErrDynDNS2HoldOff, syncA(provider, "10.0.0.1"))
+	assert.Equal(t, 1, *requests)
+}
+
+func TestDynDNS2BadStatus(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprintln(w, "<html>Bad Gateway</html>")
+	}))
+	defer server.Close()
+	provider, err := NewDynDNS2(ProviderConfig{"server": server.URL, "username": "user",
+		"password": "pass", "record": "sub.domain.com"})
+	assert.NoError(t, err)
+
+	// the error page is not a response code, and can be retried
+	err = syncA(provider, "10.0.0.1")
+	assert.Error(t, err)
+	assert.False(t, IsPermanent(err))
+	assert.Error(t, syncA(provider, "10.0.0.1"))
+	assert.Equal(t, 2, requests)
+}
+
+func TestDynDNS2UnknownHoldsUpdates(t *testing.T) {
+	provider, requests, stop := newTestDynDNS2(t, "wat")
+	defer stop()
+
+	err := syncA(provider, "10.0.0.1")
+	assert.Error(t, err)
+	assert.True(t, IsPermanent(err))
+	assert.Equal(t, ErrDynDNS2HoldOff, syncA(provider, "10.0.0.1"))
+	assert.Equal(t, 1, *requests)
+}
+
+func syncA(provider *DynDNS2, ipAddress string) error {
+	_, err := provider.SyncRecord(context.Background(), "A", ipAddress)
+	return err
+}
@@ -0,0 +1,74 @@
+package dns
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// permanent is implemented by errors that know if a retry could fix them
+type permanent interface {
+	Permanent() bool
+}
+
+// permanentError marks an error that retrying will not fix
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+// Cause returns the marked error
+func (e permanentError) Cause() error {
+	return e.err
+}
+
+// Unwrap returns the marked error
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent returns true
+func (e permanentError) Permanent() bool {
+	return true
+}
+
+// Permanent marks err as an error that retrying will not fix, ie. bad
+// credentials or a missing zone
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+// IsPermanent returns true if retrying the sync that returned err will
+// not help
+func IsPermanent(err error) bool {
+	for err != nil {
+		if p, ok := err.(permanent); ok {
+			return p.Permanent()
+		}
+		cause, ok := err.(interface{ Cause() error })
+		if !ok {
+			return false
+		}
+		err = cause.Cause()
+	}
+	return false
+}
+
+// permanentStatus marks err as permanent when the http status says the
+// credentials or the zone are wrong
+func permanentStatus(statusCode int, err error) error {
+	switch statusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return Permanent(err)
+	}
+	return err
+}
+
+// errRoundRobin is returned when a record has more then one value
+var errRoundRobin = Permanent(errors.New("Found more then one matching record"))
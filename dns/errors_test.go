@@ -0,0 +1,33 @@
+package dns
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	perrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPermanent(t *testing.T) {
+	err := errors.New("failure")
+	assert.False(t, IsPermanent(nil))
+	assert.False(t, IsPermanent(err))
+	assert.Nil(t, Permanent(nil))
+	assert.True(t, IsPermanent(Permanent(err)))
+	assert.Equal(t, "failure", Permanent(err).Error())
+	assert.True(t, IsPermanent(perrors.Wrap(Permanent(err), "wrapped")))
+	assert.True(t, IsPermanent(ErrDynDNS2BadAuth))
+}
+
+func TestPermanentStatus(t *testing.T) {
+	err := errors.New("failure")
+	assert.True(t, IsPermanent(permanentStatus(http.StatusUnauthorized, err)))
+	assert.True(t, IsPermanent(permanentStatus(http.StatusNotFound, err)))
+	assert.False(t, IsPermanent(permanentStatus(http.StatusBadGateway, err)))
+}
+
+func TestCloudflareError(t *testing.T) {
+	assert.True(t, IsPermanent(cloudflareError(errors.New("HTTP status 403: insufficient permissions"))))
+	assert.False(t, IsPermanent(cloudflareError(errors.New("HTTP status 502: service failure"))))
+}
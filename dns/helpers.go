@@ -1,7 +1,9 @@
 package dns
 
 import (
+	"context"
 	"net"
+	"net/http"
 	"strings"
 )
 
@@ -30,3 +32,26 @@ func IsValidIPv6Addr(addr string) bool {
 	ip := net.ParseIP(addr)
 	return ip.To16() != nil
 }
+
+// fqdn returns the given name as a fully qualified domain name
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// contextTransport adds a context to every request, for api clients that
+// do not accept one
+type contextTransport struct {
+	ctx context.Context
+}
+
+func (t contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return http.DefaultTransport.RoundTrip(req.WithContext(t.ctx))
+}
+
+// contextClient returns an http client whose requests are canceled with ctx
+func contextClient(ctx context.Context) *http.Client {
+	return &http.Client{Transport: contextTransport{ctx}}
+}
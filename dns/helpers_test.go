@@ -35,3 +35,8 @@ func TestBadShortSplit(t *testing.T) {
 	assert.Equal(t, "@", record)
 	assert.Equal(t, ".com", domain)
 }
+
+func TestFqdn(t *testing.T) {
+	assert.Equal(t, "sub.domain.com.", fqdn("sub.domain.com"))
+	assert.Equal(t, "sub.domain.com.", fqdn("sub.domain.com."))
+}
@@ -0,0 +1,225 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	mdns "github.com/miekg/dns"
+	"github.com/sirupsen/logrus"
+)
+
+const rfc2136Name = "rfc2136"
+
+const rfc2136DefaultPort = "53"
+const rfc2136DefaultTTL = 300
+
+// tsigAlgorithms maps the config algorithm names to the TSIG algorithm names
+var tsigAlgorithms = map[string]string{
+	"hmac-sha256": mdns.HmacSHA256,
+	"hmac-sha512": mdns.HmacSHA512,
+}
+
+// RFC2136DNS instance
+type RFC2136DNS struct {
+	name      Name
+	server    string
+	zone      string
+	keyName   string
+	secret    string
+	algorithm string
+	ttl       uint32
+	timeout   time.Duration
+	record    string
+	log       *logrus.Entry
+}
+
+// NewRFC2136DNS is RFC2136DNS constructor
+func NewRFC2136DNS(config ProviderConfig) (*RFC2136DNS, error) {
+	r := &RFC2136DNS{}
+	r.name = rfc2136Name
+	server, ok := config["server"]
+	if !ok {
+		return r, errors.New("server missing from RFC2136 provider")
+	}
+	r.record, ok = config["record"]
+	if !ok {
+		return r, errors.New("record missing from RFC2136 provider")
+	}
+	port, ok := config["port"]
+	if !ok || port == "" {
+		port = rfc2136DefaultPort
+	}
+	r.server = net.JoinHostPort(server, port)
+
+	r.zone = config["zone"]
+	if r.zone == "" {
+		r.zone, _ = SplitDomainRecord(r.record)
+	}
+	r.zone = fqdn(r.zone)
+
+	r.keyName = config["key_name"]
+	r.secret = config["secret"]
+	if (r.keyName == "") != (r.secret == "") {
+		return r, errors.New("key_name and secret must be set together in RFC2136 provider")
+	}
+	if r.keyName != "" {
+		r.keyName = fqdn(r.keyName)
+		algorithm, ok := config["algorithm"]
+		if !ok || algorithm == "" {
+			algorithm = "hmac-sha256"
+		}
+		r.algorithm, ok = tsigAlgorithms[strings.ToLower(algorithm)]
+		if !ok {
+			return r, fmt.Errorf("algorithm '%s' not supported by RFC2136 provider", algorithm)
+		}
+	}
+
+	r.ttl = rfc2136DefaultTTL
+	if ttl, ok := config["ttl"]; ok && ttl != "" {
+		value, err := strconv.ParseUint(ttl, 10, 32)
+		if err != nil || value == 0 {
+			return r, errors.New("ttl must be a positive number in RFC2136 provider")
+		}
+		r.ttl = uint32(value)
+	}
+	r.timeout = 10 * time.Second
+
+	r.log = log.WithFields(logrus.Fields{"dns": "rfc"})
+	return r, nil
+}
+
+// GetName returns name identifier
+func (r *RFC2136DNS) GetName() Name {
+	return r.name
+}
+
+// GetRecord returns the record being synced
+func (r *RFC2136DNS) GetRecord() string {
+	return r.record
+}
+
+// SyncRecord sets the given record to match ipAddress
+func (r *RFC2136DNS) SyncRecord(ctx context.Context, recordType string, ipAddress string) (Result, error) {
+	result := NewResult(r, recordType, ipAddress)
+	rrType, ok := mdns.StringToType[recordType]
+	if !ok {
+		return result, Permanent(fmt.Errorf("record type '%s' not supported", recordType))
+	}
+	current, err := r.CurrentRecord(ctx, recordType)
+	if err != nil {
+		return result, err
+	}
+	result = PlanRecord(r, recordType, ipAddress, current)
+	switch result.Action {
+	case Unchanged:
+		r.log.Infof("rfc: record does not need to be updated")
+		return result, nil
+	case Created:
+		r.log.Infof("rfc: no matching record found, will attempt to create")
+	default:
+		r.log.WithFields(logrus.Fields{
+			"ip": result.OldValue,
+		}).Infof("rfc: updating record")
+	}
+
+	// Else, replace the record set with our address
+	rr, err := mdns.NewRR(fmt.Sprintf("%s %d IN %s %s", fqdn(r.record), r.ttl, recordType, ipAddress))
+	if err != nil {
+		r.log.Errorf("rfc: could not build record: %v", err)
+		return result, err
+	}
+	update := new(mdns.Msg)
+	update.SetUpdate(r.zone)
+	update.RemoveRRset([]mdns.RR{&mdns.ANY{Hdr: mdns.RR_Header{
+		Name:   fqdn(r.record),
+		Rrtype: rrType,
+		Class:  mdns.ClassINET,
+	}}})
+	update.Insert([]mdns.RR{rr})
+	_, err = r.exchange(ctx, update)
+	if err != nil {
+		r.log.WithFields(logrus.Fields{
+			"zone": r.zone,
+			"err":  err,
+		}).Errorf("rfc: could not update domain record")
+		return result, err
+	}
+	r.log.Infof("rfc: record successfully updated")
+
+	return result, nil
+}
+
+// CurrentRecord returns the record as the server answers it
+func (r *RFC2136DNS) CurrentRecord(ctx context.Context, recordType string) (Record, error) {
+	rrType, ok := mdns.StringToType[recordType]
+	if !ok {
+		return Record{}, Permanent(fmt.Errorf("record type '%s' not supported", recordType))
+	}
+	r.log.Debugf("rfc: searching for zone=%s record=%s server=%s", r.zone, r.record, r.server)
+
+	values, err := r.getRecordSet(ctx, rrType)
+	if err != nil {
+		r.log.WithFields(logrus.Fields{
+			"server": r.server,
+			"err":    err,
+		}).Errorf("rfc: could not query the current record")
+		return Record{}, err
+	}
+	r.log.Debugf("rfc: %d matching records found", len(values))
+	return Record{Values: values}, nil
+}
+
+func (r *RFC2136DNS) getRecordSet(ctx context.Context, rrType uint16) ([]string, error) {
+	query := new(mdns.Msg)
+	query.SetQuestion(fqdn(r.record), rrType)
+	query.RecursionDesired = false
+	res, err := r.exchange(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	values := []string{}
+	for _, answer := range res.Answer {
+		switch rr := answer.(type) {
+		case *mdns.A:
+			values = append(values, rr.A.String())
+		case *mdns.AAAA:
+			values = append(values, rr.AAAA.String())
+		}
+	}
+	return values, nil
+}
+
+// exchange signs the message when a key is configured, sends it, and
+// checks the response code
+func (r *RFC2136DNS) exchange(ctx context.Context, msg *mdns.Msg) (*mdns.Msg, error) {
+	client := new(mdns.Client)
+	client.Timeout = r.timeout
+	if r.keyName != "" {
+		client.TsigSecret = map[string]string{r.keyName: r.secret}
+		msg.SetTsig(r.keyName, r.algorithm, 300, time.Now().Unix())
+	}
+
+	res, _, err := client.ExchangeContext(ctx, msg, r.server)
+	if err == mdns.ErrSig || err == mdns.ErrAuth || err == mdns.ErrKeyAlg {
+		return nil, Permanent(err)
+	} else if err != nil {
+		return nil, err
+	}
+	// NXDOMAIN just means the record does not exist yet
+	if res.Rcode != mdns.RcodeSuccess && res.Rcode != mdns.RcodeNameError {
+		err = fmt.Errorf("server responded with %s", mdns.RcodeToString[res.Rcode])
+		switch res.Rcode {
+		case mdns.RcodeRefused, mdns.RcodeNotAuth, mdns.RcodeNotZone,
+			mdns.RcodeBadSig, mdns.RcodeBadKey, mdns.RcodeBadTime:
+			return nil, Permanent(err)
+		}
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,161 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	mdns "github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+const testTsigKey = "dyngo."
+const testTsigSecret = "c2VjcmV0c2VjcmV0c2VjcmV0c2VjcmV0"
+
+type fakeNameserver struct {
+	records map[uint16][]mdns.RR
+	updates int
+	mutex   sync.Mutex
+}
+
+func (f *fakeNameserver) ServeDNS(w mdns.ResponseWriter, req *mdns.Msg) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	res := new(mdns.Msg)
+	res.SetReply(req)
+	if req.IsTsig() == nil || w.TsigStatus() != nil {
+		res.Rcode = mdns.RcodeNotAuth
+	} else if req.Opcode == mdns.OpcodeUpdate {
+		for _, rr := range req.Ns {
+			if rr.Header().Class == mdns.ClassANY {
+				delete(f.records, rr.Header().Rrtype)
+			} else {
+				f.records[rr.Header().Rrtype] = append(f.records[rr.Header().Rrtype], rr)
+			}
+		}
+		f.updates++
+	} else {
+		res.Answer = f.records[req.Question[0].Qtype]
+	}
+	if req.IsTsig() != nil {
+		res.SetTsig(testTsigKey, mdns.HmacSHA256, 300, int64(req.IsTsig().TimeSigned))
+	}
+	w.WriteMsg(res)
+}
+
+func (f *fakeNameserver) updateCount() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.updates
+}
+
+func (f *fakeNameserver) recordSet(rrType uint16) []mdns.RR {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.records[rrType]
+}
+
+func newTestRFC2136(t *testing.T, fake *fakeNameserver, secret string) (*RFC2136DNS, func()) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	server := &mdns.Server{
+		PacketConn: conn,
+		Handler:    fake,
+		TsigSecret: map[string]string{testTsigKey: testTsigSecret},
+		MsgAcceptFunc: func(dh mdns.Header) mdns.MsgAcceptAction {
+			return mdns.MsgAccept
+		},
+	}
+	go server.ActivateAndServe()
+
+	host, port, _ := net.SplitHostPort(conn.LocalAddr().String())
+	provider, err := NewRFC2136DNS(ProviderConfig{
+		"server":   host,
+		"port":     port,
+		"record":   "sub.domain.com",
+		"key_name": "dyngo",
+		"secret":   secret,
+	})
+	assert.NoError(t, err)
+	return provider, func() { server.Shutdown() }
+}
+
+func TestRFC2136Config(t *testing.T) {
+	_, err := NewRFC2136DNS(ProviderConfig{"record": "sub.domain.com"})
+	assert.Error(t, err)
+
+	_, err = NewRFC2136DNS(ProviderConfig{
+		"server": "127.0.0.1", "record": "sub.domain.com", "key_name": "dyngo",
+	})
+	assert.Error(t, err, "secret without key should fail")
+
+	_, err = NewRFC2136DNS(ProviderConfig{
+		"server": "127.0.0.1", "record": "sub.domain.com", "key_name": "dyngo",
+		"secret": testTsigSecret, "algorithm": "hmac-md5",
+	})
+	assert.Error(t, err, "unsupported algorithm should fail")
+
+	provider, err := NewRFC2136DNS(ProviderConfig{
+		"server": "ns1.domain.com", "record": "sub.domain.com",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "domain.com.", provider.zone)
+	assert.Equal(t, "ns1.domain.com:53", provider.server)
+}
+
+func TestRFC2136CreateAndUpdate(t *testing.T) {
+	fake := &fakeNameserver{records: map[uint16][]mdns.RR{}}
+	provider, stop := newTestRFC2136(t, fake, testTsigSecret)
+	defer stop()
+
+	result, err := provider.SyncRecord(context.Background(), "A", "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, Created, result.Action)
+	assert.Equal(t, 1, fake.updateCount())
+	assert.Len(t, fake.recordSet(mdns.TypeA), 1)
+
+	result, err = provider.SyncRecord(context.Background(), "A", "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, Unchanged, result.Action)
+	assert.Equal(t, 1, fake.updateCount(), "unchanged record should not be updated")
+
+	result, err = provider.SyncRecord(context.Background(), "A", "10.0.0.2")
+	assert.NoError(t, err)
+	assert.Equal(t, Updated, result.Action)
+	assert.Equal(t, "10.0.0.1", result.OldValue)
+	assert.Equal(t, 2, fake.updateCount())
+	assert.Len(t, fake.recordSet(mdns.TypeA), 1)
+	assert.Equal(t, "10.0.0.2", fake.recordSet(mdns.TypeA)[0].(*mdns.A).A.String())
+}
+
+func TestRFC2136BadKey(t *testing.T) {
+	fake := &fakeNameserver{records: map[uint16][]mdns.RR{}}
+	provider, stop := newTestRFC2136(t, fake, "d3JvbmdzZWNyZXQ=")
+	defer stop()
+
+	_, err := provider.SyncRecord(context.Background(), "AAAA", "2001:db8::1")
+	assert.Error(t, err)
+	assert.True(t, IsPermanent(err), "bad keys should not be retried")
+	assert.Equal(t, 0, fake.updateCount())
+}
+
+func TestRFC2136CurrentRecord(t *testing.T) {
+	fake := &fakeNameserver{records: map[uint16][]mdns.RR{}}
+	provider, stop := newTestRFC2136(t, fake, testTsigSecret)
+	defer stop()
+
+	current, err := provider.CurrentRecord(context.Background(), "A")
+	assert.NoError(t, err)
+	assert.Empty(t, current.Values)
+
+	_, err = provider.SyncRecord(context.Background(), "A", "10.0.0.1")
+	assert.NoError(t, err)
+	current, err = provider.CurrentRecord(context.Background(), "A")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1"}, current.Values)
+	assert.Equal(t, 1, fake.updateCount())
+
+	_, err = provider.CurrentRecord(context.Background(), "BOGUS")
+	assert.True(t, IsPermanent(err))
+}
@@ -0,0 +1,286 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/sirupsen/logrus"
+)
+
+const route53Name = "route53"
+
+const route53DefaultRegion = "us-east-1"
+const route53DefaultTTL = 300
+
+// Route53DNS instance
+type Route53DNS struct {
+	name         Name
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	hostedZoneID string
+	region       string
+	endpoint     string
+	ttl          int64
+	api          *route53.Route53
+	record       string
+	log          *logrus.Entry
+}
+
+// NewRoute53DNS is Route53DNS constructor
+func NewRoute53DNS(config ProviderConfig) (*Route53DNS, error) {
+	r := &Route53DNS{}
+	r.name = route53Name
+	var ok bool
+	r.accessKey, ok = config["access_key_id"]
+	if !ok {
+		return r, errors.New("access_key_id missing from Route53 provider")
+	}
+	r.secretKey, ok = config["secret_access_key"]
+	if !ok {
+		return r, errors.New("secret_access_key missing from Route53 provider")
+	}
+	r.record, ok = config["record"]
+	if !ok {
+		return r, errors.New("record missing from Route53 provider")
+	}
+	r.sessionToken = config["session_token"]
+	r.hostedZoneID = config["hosted_zone_id"]
+	r.endpoint = config["endpoint"]
+
+	r.region = route53DefaultRegion
+	if region, ok := config["region"]; ok && region != "" {
+		r.region = region
+	}
+	r.ttl = route53DefaultTTL
+	if ttl, ok := config["ttl"]; ok && ttl != "" {
+		value, err := strconv.ParseInt(ttl, 10, 64)
+		if err != nil || value <= 0 {
+			return r, errors.New("ttl must be a positive number in Route53 provider")
+		}
+		r.ttl = value
+	}
+
+	r.log = log.WithFields(logrus.Fields{"dns": "r53"})
+	return r, nil
+}
+
+// GetName returns name identifier
+func (r *Route53DNS) GetName() Name {
+	return r.name
+}
+
+// GetRecord returns the record being synced
+func (r *Route53DNS) GetRecord() string {
+	return r.record
+}
+
+// SyncRecord sets the given record to match ipAddress
+func (r *Route53DNS) SyncRecord(ctx context.Context, recordType string, ipAddress string) (Result, error) {
+	result := NewResult(r, recordType, ipAddress)
+	zoneID, recordSet, err := r.findRecordSet(ctx, recordType)
+	if err != nil {
+		return result, err
+	}
+	current, err := r.currentRecord(recordSet)
+	if err != nil {
+		return result, err
+	}
+	result = PlanRecord(r, recordType, ipAddress, current)
+
+	switch result.Action {
+	case Unchanged:
+		r.log.Infof("r53: record does not need to be updated")
+		return result, nil
+	case Created:
+		r.log.Infof("r53: no matching record found, will attempt to create")
+	default:
+		r.log.WithFields(logrus.Fields{
+			"ip": result.OldValue,
+		}).Infof("r53: updating record")
+	}
+
+	// Else, we need to upsert the record set
+	err = r.upsertRecordSet(ctx, zoneID, recordType, ipAddress)
+	if err != nil {
+		r.log.WithFields(logrus.Fields{
+			"domain": r.record,
+			"zone":   zoneID,
+			"err":    err,
+		}).Errorf("r53: could not upsert domain record")
+		return result, route53Error(err)
+	}
+	r.log.Infof("r53: record successfully updated")
+
+	return result, nil
+}
+
+// CurrentRecord returns the record as it is set at Route53
+func (r *Route53DNS) CurrentRecord(ctx context.Context, recordType string) (Record, error) {
+	_, recordSet, err := r.findRecordSet(ctx, recordType)
+	if err != nil {
+		return Record{}, err
+	}
+	return r.currentRecord(recordSet)
+}
+
+// findRecordSet returns the hosted zone of the record and the record set
+// that matches it, or nil if there is none
+func (r *Route53DNS) findRecordSet(ctx context.Context, recordType string) (string, *route53.ResourceRecordSet, error) {
+	// Authenticate with AWS
+	var err error
+	r.api, err = r.newAPI()
+	if err != nil {
+		r.log.Errorf("r53: could not create session: %v", err)
+		return "", nil, Permanent(err)
+	}
+	domainName, recordName := SplitDomainRecord(r.record)
+	r.log.Debugf("r53: searching for domain=%s record=%s", domainName, recordName)
+
+	// First find the hosted zone the record lives in
+	zoneID, err := r.getHostedZoneID(ctx, domainName)
+	if err != nil {
+		r.log.WithFields(logrus.Fields{
+			"domain": domainName,
+			"err":    err,
+		}).Errorf("r53: could not find the hosted zone")
+		return "", nil, route53Error(err)
+	}
+
+	// Then look for a record set that matches ours
+	recordSet, err := r.getRecordSet(ctx, zoneID, recordType)
+	if err != nil {
+		r.log.WithFields(logrus.Fields{
+			"zone": zoneID,
+			"err":  err,
+		}).Errorf("r53: could not get a list of records")
+		return "", nil, route53Error(err)
+	}
+	return zoneID, recordSet, nil
+}
+
+// currentRecord returns the current record of a record set, round robin
+// record sets are not supported
+func (r *Route53DNS) currentRecord(recordSet *route53.ResourceRecordSet) (Record, error) {
+	if recordSet == nil {
+		return Record{}, nil
+	}
+	values := recordSetValues(recordSet)
+	r.log.WithFields(logrus.Fields{
+		"ip": strings.Join(values, ","),
+	}).Debugf("r53: found matching record")
+	if len(values) > 1 {
+		r.log.Errorf("r53: Found %d record values, will not update a round robin record set", len(values))
+		return Record{}, errRoundRobin
+	}
+	return Record{Values: values}, nil
+}
+
+func (r *Route53DNS) newAPI() (*route53.Route53, error) {
+	config := aws.NewConfig().
+		WithRegion(r.region).
+		WithCredentials(credentials.NewStaticCredentials(r.accessKey, r.secretKey, r.sessionToken))
+	if r.endpoint != "" {
+		config = config.WithEndpoint(r.endpoint)
+	}
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return nil, err
+	}
+	return route53.New(sess), nil
+}
+
+func (r *Route53DNS) getHostedZoneID(ctx context.Context, domainName string) (string, error) {
+	if r.hostedZoneID != "" {
+		return r.hostedZoneID, nil
+	}
+
+	res, err := r.api.ListHostedZonesByNameWithContext(ctx, &route53.ListHostedZonesByNameInput{
+		DNSName:  aws.String(domainName),
+		MaxItems: aws.String("1"),
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, zone := range res.HostedZones {
+		if fqdn(aws.StringValue(zone.Name)) == fqdn(domainName) {
+			// zone ids are returned as '/hostedzone/<id>'
+			return strings.TrimPrefix(aws.StringValue(zone.Id), "/hostedzone/"), nil
+		}
+	}
+	return "", Permanent(errors.New("no hosted zone found for domain"))
+}
+
+func (r *Route53DNS) getRecordSet(ctx context.Context, zoneID string, recordType string) (*route53.ResourceRecordSet, error) {
+	res, err := r.api.ListResourceRecordSetsWithContext(ctx, &route53.ListResourceRecordSetsInput{
+		HostedZoneId:    aws.String(zoneID),
+		StartRecordName: aws.String(fqdn(r.record)),
+		StartRecordType: aws.String(recordType),
+		MaxItems:        aws.String("1"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	// the listing starts at our record, but returns the next one if ours is missing
+	for _, recordSet := range res.ResourceRecordSets {
+		if fqdn(aws.StringValue(recordSet.Name)) == fqdn(r.record) &&
+			aws.StringValue(recordSet.Type) == recordType {
+			return recordSet, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *Route53DNS) upsertRecordSet(ctx context.Context, zoneID string, recordType string, ipAddress string) error {
+	_, err := r.api.ChangeResourceRecordSetsWithContext(ctx, &route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(zoneID),
+		ChangeBatch: &route53.ChangeBatch{
+			Comment: aws.String("dyngo update"),
+			Changes: []*route53.Change{
+				{
+					Action: aws.String(route53.ChangeActionUpsert),
+					ResourceRecordSet: &route53.ResourceRecordSet{
+						Name: aws.String(fqdn(r.record)),
+						Type: aws.String(recordType),
+						TTL:  aws.Int64(r.ttl),
+						ResourceRecords: []*route53.ResourceRecord{
+							{Value: aws.String(ipAddress)},
+						},
+					},
+				},
+			},
+		},
+	})
+	return err
+}
+
+func recordSetValues(recordSet *route53.ResourceRecordSet) []string {
+	values := make([]string, 0, len(recordSet.ResourceRecords))
+	for _, record := range recordSet.ResourceRecords {
+		values = append(values, aws.StringValue(record.Value))
+	}
+	return values
+}
+
+// route53Error marks the errors retrying will not fix as permanent
+func route53Error(err error) error {
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case "AccessDenied", "InvalidClientTokenId", "SignatureDoesNotMatch",
+			"UnrecognizedClientException", route53.ErrCodeNoSuchHostedZone,
+			route53.ErrCodeInvalidChangeBatch:
+			return Permanent(err)
+		}
+	}
+	if rerr, ok := err.(awserr.RequestFailure); ok {
+		return permanentStatus(rerr.StatusCode(), err)
+	}
+	return err
+}
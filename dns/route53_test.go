@@ -0,0 +1,145 @@
+package dns
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeRoute53 struct {
+	zoneName string
+	records  map[string]string
+	changes  int
+}
+
+type fakeRoute53Change struct {
+	Action string `xml:"ChangeBatch>Changes>Change>Action"`
+	Name   string `xml:"ChangeBatch>Changes>Change>ResourceRecordSet>Name"`
+	Type   string `xml:"ChangeBatch>Changes>Change>ResourceRecordSet>Type"`
+	Value  string `xml:"ChangeBatch>Changes>Change>ResourceRecordSet>ResourceRecords>ResourceRecord>Value"`
+}
+
+func (f *fakeRoute53) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/xml")
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/hostedzonesbyname"):
+		fmt.Fprintf(w, `<ListHostedZonesByNameResponse><HostedZones><HostedZone>
+<Id>/hostedzone/ZFAKE</Id><Name>%s</Name></HostedZone></HostedZones>
+</ListHostedZonesByNameResponse>`, f.zoneName)
+	case strings.HasSuffix(r.URL.Path, "/rrset") && r.Method == http.MethodGet:
+		name := r.URL.Query().Get("name")
+		recordType := r.URL.Query().Get("type")
+		value, ok := f.records[name+recordType]
+		if !ok {
+			fmt.Fprint(w, `<ListResourceRecordSetsResponse><ResourceRecordSets/></ListResourceRecordSetsResponse>`)
+			return
+		}
+		fmt.Fprintf(w, `<ListResourceRecordSetsResponse><ResourceRecordSets><ResourceRecordSet>
+<Name>%s</Name><Type>%s</Type><TTL>300</TTL><ResourceRecords><ResourceRecord><Value>%s</Value></ResourceRecord></ResourceRecords>
+</ResourceRecordSet></ResourceRecordSets></ListResourceRecordSetsResponse>`, name, recordType, value)
+	case strings.HasSuffix(r.URL.Path, "/rrset/") && r.Method == http.MethodPost:
+		body, _ := ioutil.ReadAll(r.Body)
+		change := fakeRoute53Change{}
+		if err := xml.Unmarshal(body, &change); err != nil || change.Action != "UPSERT" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.records[change.Name+change.Type] = change.Value
+		f.changes++
+		fmt.Fprint(w, `<ChangeResourceRecordSetsResponse><ChangeInfo><Id>/change/C1</Id>
+<Status>PENDING</Status></ChangeInfo></ChangeResourceRecordSetsResponse>`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestRoute53(t *testing.T, fake *fakeRoute53) (*Route53DNS, func()) {
+	server := httptest.NewServer(fake)
+	provider, err := NewRoute53DNS(ProviderConfig{
+		"record":            "sub.domain.com",
+		"access_key_id":     "AKIDEXAMPLE",
+		"secret_access_key": "secret",
+		"endpoint":          server.URL,
+	})
+	assert.NoError(t, err)
+	return provider, server.Close
+}
+
+func TestRoute53MissingConfig(t *testing.T) {
+	_, err := NewRoute53DNS(ProviderConfig{"record": "sub.domain.com"})
+	assert.Error(t, err)
+
+	_, err = NewRoute53DNS(ProviderConfig{
+		"record":            "sub.domain.com",
+		"access_key_id":     "AKIDEXAMPLE",
+		"secret_access_key": "secret",
+		"ttl":               "soon",
+	})
+	assert.Error(t, err)
+}
+
+func TestRoute53CreateRecord(t *testing.T) {
+	fake := &fakeRoute53{zoneName: "domain.com.", records: map[string]string{}}
+	provider, stop := newTestRoute53(t, fake)
+	defer stop()
+
+	result, err := provider.SyncRecord(context.Background(), "A", "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, Created, result.Action)
+	assert.Equal(t, 1, fake.changes)
+	assert.Equal(t, "10.0.0.1", fake.records["sub.domain.com.A"])
+}
+
+func TestRoute53UnchangedRecord(t *testing.T) {
+	fake := &fakeRoute53{zoneName: "domain.com.", records: map[string]string{
+		"sub.domain.com.AAAA": "2001:db8::1",
+	}}
+	provider, stop := newTestRoute53(t, fake)
+	defer stop()
+
+	result, err := provider.SyncRecord(context.Background(), "AAAA", "2001:db8::1")
+	assert.NoError(t, err)
+	assert.Equal(t, Unchanged, result.Action)
+	assert.Equal(t, 0, fake.changes)
+
+	result, err = provider.SyncRecord(context.Background(), "AAAA", "2001:db8::2")
+	assert.NoError(t, err)
+	assert.Equal(t, Updated, result.Action)
+	assert.Equal(t, "2001:db8::1", result.OldValue)
+	assert.Equal(t, 1, fake.changes)
+	assert.Equal(t, "2001:db8::2", fake.records["sub.domain.com.AAAA"])
+}
+
+func TestRoute53MissingZone(t *testing.T) {
+	fake := &fakeRoute53{zoneName: "other.com.", records: map[string]string{}}
+	provider, stop := newTestRoute53(t, fake)
+	defer stop()
+
+	_, err := provider.SyncRecord(context.Background(), "A", "10.0.0.1")
+	assert.Error(t, err)
+	assert.True(t, IsPermanent(err), "missing zones should not be retried")
+	assert.Equal(t, 0, fake.changes)
+}
+
+func TestRoute53CurrentRecord(t *testing.T) {
+	fake := &fakeRoute53{zoneName: "domain.com.", records: map[string]string{
+		"sub.domain.com.A": "10.0.0.1",
+	}}
+	provider, stop := newTestRoute53(t, fake)
+	defer stop()
+
+	current, err := provider.CurrentRecord(context.Background(), "A")
+	assert.NoError(t, err)
+	assert.Equal(t, Record{Values: []string{"10.0.0.1"}}, current)
+	current, err = provider.CurrentRecord(context.Background(), "AAAA")
+	assert.NoError(t, err)
+	assert.Empty(t, current.Values)
+	assert.Equal(t, 0, fake.changes)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gesquive/dyngo/notify"
+	"github.com/spf13/viper"
+)
+
+// hooks runs the commands configured for events of the running service
+var hooks = notify.NewDispatcher()
+
+// hookEvents maps the hook config keys to the events they run on
+var hookEvents = []struct {
+	key    string
+	events []notify.EventType
+}{
+	{"on_ip_change", []notify.EventType{notify.IPChanged}},
+	{"on_update", []notify.EventType{notify.RecordUpdated, notify.RecordCreated}},
+	{"on_failure", []notify.EventType{notify.SyncFailed}},
+}
+
+// hooksConfig is the hook setup in the config
+type hooksConfig struct {
+	subscriptions  []notify.Subscription
+	remindInterval time.Duration
+}
+
+// configureHooks sets up the hooks in the config
+func configureHooks() error {
+	config, err := readHooks()
+	if err != nil {
+		return err
+	}
+	config.apply()
+	return nil
+}
+
+// readHooks returns the hook setup in the config, without using it
+func readHooks() (hooksConfig, error) {
+	config := hooksConfig{}
+	var err error
+	if config.subscriptions, err = getHooks(); err != nil {
+		return config, err
+	}
+	if config.remindInterval, err = time.ParseDuration(viper.GetString("notifications.remind_interval")); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// apply makes config the running hook setup, each hook has its own timeout
+// so the dispatcher does not limit them
+func (config hooksConfig) apply() {
+	hooks.Configure(config.subscriptions, config.remindInterval, 0)
+}
@@ -0,0 +1,109 @@
+package ipcheck
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	mdns "github.com/miekg/dns"
+)
+
+const dnsScheme = "dns"
+
+// DNSSource gets our address by asking a resolver that answers with the
+// address of the client (ie. myip.opendns.com)
+type DNSSource struct {
+	resolver string
+	name     string
+	qtype    uint16
+	qclass   uint16
+	network  string
+	timeout  time.Duration
+}
+
+// NewDNSSource is DNSSource constructor, urls take the form
+// dns://<resolver>[:port]/<name>[?type=A|AAAA|TXT&class=IN|CH]
+func NewDNSSource(sourceURL *url.URL, config SourceConfig, family Family) (*DNSSource, error) {
+	d := &DNSSource{}
+	if sourceURL.Hostname() == "" {
+		return d, fmt.Errorf("resolver missing from dns source '%s'", sourceURL)
+	}
+	port := sourceURL.Port()
+	if port == "" {
+		port = "53"
+	}
+	d.resolver = net.JoinHostPort(sourceURL.Hostname(), port)
+	d.name = strings.Trim(sourceURL.Path, "/")
+	if d.name == "" {
+		return d, fmt.Errorf("name missing from dns source '%s'", sourceURL)
+	}
+	d.name = mdns.Fqdn(d.name)
+
+	query := sourceURL.Query()
+	d.qtype = mdns.TypeA
+	d.network = "udp4"
+	if family == IPv6 {
+		d.qtype = mdns.TypeAAAA
+		d.network = "udp6"
+	}
+	if qtype := query.Get("type"); qtype != "" {
+		var ok bool
+		d.qtype, ok = mdns.StringToType[strings.ToUpper(qtype)]
+		if !ok || (d.qtype != mdns.TypeA && d.qtype != mdns.TypeAAAA && d.qtype != mdns.TypeTXT) {
+			return d, fmt.Errorf("query type '%s' not supported by dns source", qtype)
+		}
+	}
+	d.qclass = mdns.ClassINET
+	if qclass := query.Get("class"); qclass != "" {
+		var ok bool
+		d.qclass, ok = mdns.StringToClass[strings.ToUpper(qclass)]
+		if !ok {
+			return d, fmt.Errorf("query class '%s' not supported by dns source", qclass)
+		}
+	}
+	d.timeout = 10 * time.Second
+	if config.Timeout > 0 {
+		d.timeout = config.Timeout
+	}
+	return d, nil
+}
+
+// GetAddress returns the address found in the first answer
+func (d *DNSSource) GetAddress() (string, error) {
+	query := new(mdns.Msg)
+	query.SetQuestion(d.name, d.qtype)
+	query.Question[0].Qclass = d.qclass
+
+	client := &mdns.Client{Net: d.network, Timeout: d.timeout}
+	res, _, err := client.Exchange(query, d.resolver)
+	if err != nil {
+		return "", err
+	}
+	if res.Rcode != mdns.RcodeSuccess {
+		return "", fmt.Errorf("resolver responded with %s", mdns.RcodeToString[res.Rcode])
+	}
+
+	for _, answer := range res.Answer {
+		switch rr := answer.(type) {
+		case *mdns.A:
+			return rr.A.String(), nil
+		case *mdns.AAAA:
+			return rr.AAAA.String(), nil
+		case *mdns.TXT:
+			// some resolvers add other info (ie. edns client subnet) as TXT records
+			for _, txt := range rr.Txt {
+				if net.ParseIP(strings.TrimSpace(txt)) != nil {
+					return strings.TrimSpace(txt), nil
+				}
+			}
+		}
+	}
+	return "", fmt.Errorf("no address found in %d answers", len(res.Answer))
+}
+
+func (d *DNSSource) String() string {
+	return fmt.Sprintf("dns://%s/%s?type=%s&class=%s", d.resolver, strings.TrimSuffix(d.name, "."),
+		mdns.TypeToString[d.qtype], mdns.ClassToString[d.qclass])
+}
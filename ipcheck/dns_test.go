@@ -0,0 +1,101 @@
+package ipcheck
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"testing"
+
+	mdns "github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+// startResolver starts a stub resolver that answers every query with
+// the given records
+func startResolver(t *testing.T, answers ...string) (string, func()) {
+	handler := mdns.HandlerFunc(func(w mdns.ResponseWriter, req *mdns.Msg) {
+		res := new(mdns.Msg)
+		res.SetReply(req)
+		for _, answer := range answers {
+			rr, err := mdns.NewRR(fmt.Sprintf("%s 0 %s", req.Question[0].Name, answer))
+			assert.NoError(t, err)
+			rr.Header().Class = req.Question[0].Qclass
+			res.Answer = append(res.Answer, rr)
+		}
+		w.WriteMsg(res)
+	})
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	assert.NoError(t, err)
+	server := &mdns.Server{PacketConn: conn, Handler: handler}
+	go server.ActivateAndServe()
+	return conn.LocalAddr().String(), func() { server.Shutdown() }
+}
+
+func newTestDNSSource(t *testing.T, rawURL string) *DNSSource {
+	sourceURL, err := url.Parse(rawURL)
+	assert.NoError(t, err)
+	src, err := NewDNSSource(sourceURL, SourceConfig{}, IPv4)
+	assert.NoError(t, err)
+	return src
+}
+
+func TestDNSSourceConfig(t *testing.T) {
+	src := newTestDNSSource(t, "dns://1.1.1.1/whoami.cloudflare?type=txt&class=ch")
+	assert.Equal(t, "1.1.1.1:53", src.resolver)
+	assert.Equal(t, "whoami.cloudflare.", src.name)
+	assert.Equal(t, mdns.TypeTXT, src.qtype)
+	assert.Equal(t, uint16(mdns.ClassCHAOS), src.qclass)
+
+	sourceURL, _ := url.Parse("dns://resolver1.opendns.com/myip.opendns.com")
+	src, err := NewDNSSource(sourceURL, SourceConfig{}, IPv6)
+	assert.NoError(t, err)
+	assert.Equal(t, mdns.TypeAAAA, src.qtype)
+
+	sourceURL, _ = url.Parse("dns://resolver1.opendns.com/")
+	_, err = NewDNSSource(sourceURL, SourceConfig{}, IPv4)
+	assert.Error(t, err, "missing name should fail")
+
+	sourceURL, _ = url.Parse("dns://resolver1.opendns.com/myip.opendns.com?type=MX")
+	_, err = NewDNSSource(sourceURL, SourceConfig{}, IPv4)
+	assert.Error(t, err, "unsupported type should fail")
+}
+
+func TestDNSSourceA(t *testing.T) {
+	resolver, stop := startResolver(t, "IN A 192.0.2.1")
+	defer stop()
+
+	src := newTestDNSSource(t, "dns://"+resolver+"/myip.opendns.com")
+	address, err := src.GetAddress()
+	assert.NoError(t, err)
+	assert.Equal(t, "192.0.2.1", address)
+}
+
+func TestDNSSourceTXT(t *testing.T) {
+	resolver, stop := startResolver(t,
+		`IN TXT "edns0-client-subnet 192.0.2.0/24"`, `IN TXT "192.0.2.1"`)
+	defer stop()
+
+	src := newTestDNSSource(t, "dns://"+resolver+"/o-o.myaddr.l.google.com?type=TXT")
+	address, err := src.GetAddress()
+	assert.NoError(t, err)
+	assert.Equal(t, "192.0.2.1", address)
+}
+
+func TestDNSSourceChaosTXT(t *testing.T) {
+	resolver, stop := startResolver(t, `CH TXT "192.0.2.1"`)
+	defer stop()
+
+	src := newTestDNSSource(t, "dns://"+resolver+"/whoami.cloudflare?type=TXT&class=CH")
+	address, err := src.GetAddress()
+	assert.NoError(t, err)
+	assert.Equal(t, "192.0.2.1", address)
+}
+
+func TestDNSSourceNoAnswer(t *testing.T) {
+	resolver, stop := startResolver(t)
+	defer stop()
+
+	src := newTestDNSSource(t, "dns://"+resolver+"/myip.opendns.com")
+	_, err := src.GetAddress()
+	assert.Error(t, err)
+}
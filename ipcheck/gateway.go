@@ -0,0 +1,154 @@
+package ipcheck
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const gatewayScheme = "gateway"
+
+// the port NAT-PMP and PCP servers listen on
+const gatewayDefaultPort = "5351"
+
+// gatewayProtocols are the protocols a gateway source can use
+var gatewayProtocols = []string{"upnp", "natpmp", "pcp"}
+
+// gatewayDefaultProtocols are tried in order until one returns an address,
+// pcp is left out since it creates a port mapping on the router
+var gatewayDefaultProtocols = []string{"upnp", "natpmp"}
+
+// GatewaySource gets our address by asking the local router with UPnP IGD,
+// NAT-PMP or PCP
+type GatewaySource struct {
+	gateway   string
+	port      string
+	location  string
+	protocols []string
+	timeout   time.Duration
+}
+
+// NewGatewaySource is GatewaySource constructor, urls take the form
+// gateway://[router[:port]][?protocol=upnp,natpmp,pcp&location=<url>]
+func NewGatewaySource(sourceURL *url.URL, config SourceConfig, family Family) (*GatewaySource, error) {
+	g := &GatewaySource{}
+	g.gateway = sourceURL.Hostname()
+	g.port = sourceURL.Port()
+	if g.port == "" {
+		g.port = gatewayDefaultPort
+	}
+	query := sourceURL.Query()
+	g.location = query.Get("location")
+	g.protocols = gatewayDefaultProtocols
+	if protocols := query.Get("protocol"); protocols != "" {
+		g.protocols = []string{}
+		for _, protocol := range strings.Split(protocols, ",") {
+			protocol = strings.ToLower(strings.TrimSpace(protocol))
+			if !isGatewayProtocol(protocol) {
+				return g, fmt.Errorf("protocol '%s' not supported by gateway source", protocol)
+			}
+			g.protocols = append(g.protocols, protocol)
+		}
+	}
+	g.timeout = 3 * time.Second
+	if config.Timeout > 0 {
+		g.timeout = config.Timeout
+	}
+	return g, nil
+}
+
+// GetAddress returns the WAN address from the first protocol that answers
+// with a public address. Routers answer with 0.0.0.0 while the WAN link is
+// down, and with a private address behind another NAT
+func (g *GatewaySource) GetAddress() (address string, err error) {
+	for _, protocol := range g.protocols {
+		switch protocol {
+		case "upnp":
+			address, err = g.getUPnPAddress()
+		case "natpmp":
+			address, err = g.withGateway(getNATPMPAddress)
+		case "pcp":
+			address, err = g.withGateway(getPCPAddress)
+		}
+		if err == nil {
+			if ip := net.ParseIP(address); ip != nil && isPublicIP(ip) {
+				return address, nil
+			}
+			err = fmt.Errorf("%s gateway returned %s, which is not a public address", protocol, address)
+		}
+		log.Debugf("ipchk: gateway %s lookup failed: %v", protocol, err)
+	}
+	return "", err
+}
+
+func (g *GatewaySource) String() string {
+	return fmt.Sprintf("gateway://%s?protocol=%s", g.gateway, strings.Join(g.protocols, ","))
+}
+
+func (g *GatewaySource) getUPnPAddress() (string, error) {
+	location := g.location
+	if location == "" {
+		var err error
+		location, err = discoverIGD(g.timeout)
+		if err != nil {
+			return "", err
+		}
+	}
+	return getUPnPExternalAddress(location, g.timeout)
+}
+
+// withGateway calls lookup with the router address, finding the default
+// gateway when none was configured
+func (g *GatewaySource) withGateway(lookup func(string, time.Duration) (string, error)) (string, error) {
+	gateway := g.gateway
+	if gateway == "" {
+		ip, err := defaultGateway()
+		if err != nil {
+			return "", err
+		}
+		gateway = ip.String()
+	}
+	return lookup(net.JoinHostPort(gateway, g.port), g.timeout)
+}
+
+func isGatewayProtocol(protocol string) bool {
+	for _, known := range gatewayProtocols {
+		if protocol == known {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultGateway returns the IPv4 default gateway from /proc/net/route
+func defaultGateway() (net.IP, error) {
+	file, err := os.Open("/proc/net/route")
+	if err != nil {
+		return nil, errors.New("could not find the default gateway, set the router address")
+	}
+	defer file.Close()
+
+	// each line is '<iface> <destination> <gateway> ...' with little endian hex addresses
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[1] != "00000000" {
+			continue
+		}
+		raw, err := hex.DecodeString(fields[2])
+		if err != nil || len(raw) != net.IPv4len {
+			continue
+		}
+		ip := make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(ip, binary.LittleEndian.Uint32(raw))
+		return ip, nil
+	}
+	return nil, errors.New("no default gateway found, set the router address")
+}
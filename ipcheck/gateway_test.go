@@ -0,0 +1,181 @@
+package ipcheck
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// startGateway starts a udp stub that answers each request with reply
+func startGateway(t *testing.T, reply func(request []byte) []byte) (string, func()) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go func() {
+		buf := make([]byte, 1100)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if response := reply(buf[:n]); response != nil {
+				conn.WriteTo(response, addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().String(), func() { conn.Close() }
+}
+
+func newTestGatewaySource(t *testing.T, rawURL string) *GatewaySource {
+	sourceURL, err := url.Parse(rawURL)
+	assert.NoError(t, err)
+	src, err := NewGatewaySource(sourceURL, SourceConfig{}, IPv4)
+	assert.NoError(t, err)
+	return src
+}
+
+func TestGatewaySourceConfig(t *testing.T) {
+	src := newTestGatewaySource(t, "gateway://")
+	assert.Equal(t, []string{"upnp", "natpmp"}, src.protocols, "pcp is opt-in")
+	assert.Equal(t, "5351", src.port)
+
+	src = newTestGatewaySource(t, "gateway://192.168.1.1?protocol=natpmp,pcp")
+	assert.Equal(t, []string{"natpmp", "pcp"}, src.protocols)
+	assert.Equal(t, "192.168.1.1", src.gateway)
+
+	sourceURL, _ := url.Parse("gateway://?protocol=smoke-signals")
+	_, err := NewGatewaySource(sourceURL, SourceConfig{}, IPv4)
+	assert.Error(t, err)
+}
+
+func TestGatewayNATPMP(t *testing.T) {
+	gateway, stop := startGateway(t, func(request []byte) []byte {
+		response := make([]byte, natpmpResponseLength)
+		response[1] = 128
+		copy(response[8:12], net.ParseIP("198.51.100.7").To4())
+		return response
+	})
+	defer stop()
+
+	src := newTestGatewaySource(t, "gateway://"+gateway+"?protocol=natpmp")
+	address, err := src.GetAddress()
+	assert.NoError(t, err)
+	assert.Equal(t, "198.51.100.7", address)
+}
+
+func TestGatewayNotPublic(t *testing.T) {
+	for _, wan := range []string{"0.0.0.0", "192.168.1.2", "10.1.2.3", "100.64.0.1"} {
+		wan := wan
+		gateway, stop := startGateway(t, func(request []byte) []byte {
+			response := make([]byte, natpmpResponseLength)
+			response[1] = 128
+			copy(response[8:12], net.ParseIP(wan).To4())
+			return response
+		})
+
+		src := newTestGatewaySource(t, "gateway://"+gateway+"?protocol=natpmp")
+		_, err := src.GetAddress()
+		assert.Error(t, err, wan)
+		stop()
+	}
+}
+
+func TestGatewayPCP(t *testing.T) {
+	requests := 0
+	gateway, stop := startGateway(t, func(request []byte) []byte {
+		requests++
+		if len(request) != pcpRequestLength || request[0] != pcpVersion {
+			return nil
+		}
+		response := make([]byte, pcpResponseLength)
+		response[0] = pcpVersion
+		response[1] = 0x80 | pcpOpMap
+		copy(response[4:8], request[4:8])
+		copy(response[24:44], request[24:44])
+		copy(response[44:60], net.ParseIP("198.51.100.8").To16())
+		return response
+	})
+	defer stop()
+
+	src := newTestGatewaySource(t, "gateway://"+gateway+"?protocol=pcp")
+	address, err := src.GetAddress()
+	assert.NoError(t, err)
+	assert.Equal(t, "198.51.100.8", address)
+}
+
+func TestGatewayPCPError(t *testing.T) {
+	gateway, stop := startGateway(t, func(request []byte) []byte {
+		response := make([]byte, pcpResponseLength)
+		response[0] = pcpVersion
+		response[1] = 0x80 | pcpOpMap
+		response[3] = 2 // NOT_AUTHORIZED
+		return response
+	})
+	defer stop()
+
+	src := newTestGatewaySource(t, "gateway://"+gateway+"?protocol=pcp")
+	_, err := src.GetAddress()
+	assert.Error(t, err)
+}
+
+func TestGatewayUPnP(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rootDesc.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0"><device>
+<deviceType>urn:schemas-upnp-org:device:InternetGatewayDevice:1</deviceType>
+<deviceList><device><deviceType>urn:schemas-upnp-org:device:WANDevice:1</deviceType>
+<deviceList><device><deviceType>urn:schemas-upnp-org:device:WANConnectionDevice:1</deviceType>
+<serviceList><service>
+<serviceType>urn:schemas-upnp-org:service:WANIPConnection:1</serviceType>
+<controlURL>/ctl/IPConn</controlURL>
+</service></serviceList>
+</device></deviceList></device></deviceList>
+</device></root>`)
+	})
+	mux.HandleFunc("/ctl/IPConn", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Contains(t, r.Header.Get("SOAPAction"), "#GetExternalIPAddress")
+		assert.True(t, strings.Contains(string(body), "GetExternalIPAddress"))
+		fmt.Fprint(w, `<?xml version="1.0"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>
+<u:GetExternalIPAddressResponse xmlns:u="urn:schemas-upnp-org:service:WANIPConnection:1">
+<NewExternalIPAddress>198.51.100.9</NewExternalIPAddress>
+</u:GetExternalIPAddressResponse></s:Body></s:Envelope>`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	src := newTestGatewaySource(t, "gateway://?protocol=upnp&location="+
+		url.QueryEscape(server.URL+"/rootDesc.xml"))
+	address, err := src.GetAddress()
+	assert.NoError(t, err)
+	assert.Equal(t, "198.51.100.9", address)
+}
+
+func TestGatewayFallback(t *testing.T) {
+	gateway, stop := startGateway(t, func(request []byte) []byte {
+		if len(request) != 2 {
+			return nil
+		}
+		response := make([]byte, natpmpResponseLength)
+		response[1] = 128
+		binary.BigEndian.PutUint32(response[8:12], 0xc6336407)
+		return response
+	})
+	defer stop()
+
+	// the upnp location does not exist, so nat-pmp should answer
+	src := newTestGatewaySource(t, "gateway://"+gateway+
+		"?protocol=upnp,natpmp&location=http://127.0.0.1:1/rootDesc.xml")
+	address, err := src.GetAddress()
+	assert.NoError(t, err)
+	assert.Equal(t, "198.51.100.7", address)
+}
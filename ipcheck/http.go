@@ -0,0 +1,158 @@
+package ipcheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const httpScheme = "http"
+const httpsScheme = "https"
+
+// Ways of extracting the address from a response
+const (
+	extractText  = "text"
+	extractJSON  = "json"
+	extractRegex = "regex"
+)
+
+// HTTPSource gets our address from an http response
+type HTTPSource struct {
+	url     string
+	extract string
+	path    []string
+	pattern *regexp.Regexp
+	headers map[string]string
+	client  *http.Client
+}
+
+// NewHTTPSource is HTTPSource constructor
+func NewHTTPSource(sourceURL *url.URL, config SourceConfig, family Family) (*HTTPSource, error) {
+	h := &HTTPSource{}
+	h.url = sourceURL.String()
+	h.headers = config.Headers
+
+	h.extract = strings.ToLower(config.Extract)
+	if h.extract == "" {
+		switch {
+		case config.Path != "":
+			h.extract = extractJSON
+		case config.Pattern != "":
+			h.extract = extractRegex
+		default:
+			h.extract = extractText
+		}
+	}
+	switch h.extract {
+	case extractText:
+	case extractJSON:
+		if config.Path == "" {
+			return h, fmt.Errorf("path missing from json ip source '%s'", h.url)
+		}
+		h.path = strings.Split(config.Path, ".")
+	case extractRegex:
+		if config.Pattern == "" {
+			return h, fmt.Errorf("pattern missing from regex ip source '%s'", h.url)
+		}
+		var err error
+		h.pattern, err = regexp.Compile(config.Pattern)
+		if err != nil {
+			return h, fmt.Errorf("pattern is not valid in ip source '%s': %v", h.url, err)
+		}
+	default:
+		return h, fmt.Errorf("extract '%s' not supported by ip source '%s'", config.Extract, h.url)
+	}
+
+	timeout := 30 * time.Second
+	if config.Timeout > 0 {
+		timeout = config.Timeout
+	}
+	h.client = &http.Client{Timeout: timeout}
+	return h, nil
+}
+
+// GetAddress returns the address found in the response body
+func (h *HTTPSource) GetAddress() (string, error) {
+	req, err := http.NewRequest(http.MethodGet, h.url, nil)
+	if err != nil {
+		return "", err
+	}
+	for name, value := range h.headers {
+		req.Header.Set(name, value)
+	}
+	response, err := h.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected response status %s", response.Status)
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+
+	switch h.extract {
+	case extractJSON:
+		return h.extractJSON(body)
+	case extractRegex:
+		return h.extractRegex(body)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// extractJSON follows the dotted path (ie. 'data.ip' or 'results.0.ip')
+// through the json document
+func (h *HTTPSource) extractJSON(body []byte) (string, error) {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return "", fmt.Errorf("response is not valid json: %v", err)
+	}
+	for _, key := range h.path {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			var ok bool
+			value, ok = node[key]
+			if !ok {
+				return "", fmt.Errorf("key '%s' not found in json response", key)
+			}
+		case []interface{}:
+			idx, err := strconv.Atoi(key)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return "", fmt.Errorf("index '%s' not found in json response", key)
+			}
+			value = node[idx]
+		default:
+			return "", fmt.Errorf("key '%s' not found in json response", key)
+		}
+	}
+	address, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("json value at '%s' is not a string", strings.Join(h.path, "."))
+	}
+	return strings.TrimSpace(address), nil
+}
+
+// extractRegex returns the first capture group of the pattern, or the
+// whole match when the pattern has no groups
+func (h *HTTPSource) extractRegex(body []byte) (string, error) {
+	match := h.pattern.FindSubmatch(body)
+	if match == nil {
+		return "", fmt.Errorf("pattern did not match the response")
+	}
+	if len(match) > 1 {
+		return strings.TrimSpace(string(match[1])), nil
+	}
+	return strings.TrimSpace(string(match[0])), nil
+}
+
+func (h *HTTPSource) String() string {
+	return h.url
+}
@@ -0,0 +1,108 @@
+package ipcheck
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func startEchoServer(t *testing.T, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key := r.Header.Get("X-Api-Key"); key != "" {
+			assert.Equal(t, "secret", key)
+		}
+		fmt.Fprint(w, body)
+	}))
+}
+
+func getHTTPAddress(t *testing.T, config SourceConfig) (string, error) {
+	src, err := GetSource(config, IPv4)
+	assert.NoError(t, err)
+	return src.GetAddress()
+}
+
+func TestHTTPSourceText(t *testing.T) {
+	server := startEchoServer(t, "192.0.2.1\n")
+	defer server.Close()
+
+	address, err := getHTTPAddress(t, SourceConfig{URL: server.URL})
+	assert.NoError(t, err)
+	assert.Equal(t, "192.0.2.1", address)
+}
+
+func TestHTTPSourceJSON(t *testing.T) {
+	server := startEchoServer(t, `{"data": {"results": [{"ip": "192.0.2.2"}]}}`)
+	defer server.Close()
+
+	address, err := getHTTPAddress(t, SourceConfig{
+		URL:     server.URL,
+		Path:    "data.results.0.ip",
+		Headers: map[string]string{"X-Api-Key": "secret"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "192.0.2.2", address)
+
+	_, err = getHTTPAddress(t, SourceConfig{URL: server.URL, Extract: "json", Path: "data.missing"})
+	assert.Error(t, err)
+	_, err = getHTTPAddress(t, SourceConfig{URL: server.URL, Extract: "json", Path: "data.results.5.ip"})
+	assert.Error(t, err)
+}
+
+func TestHTTPSourceRegex(t *testing.T) {
+	server := startEchoServer(t, `<html><body>Current IP Address: 192.0.2.3</body></html>`)
+	defer server.Close()
+
+	address, err := getHTTPAddress(t, SourceConfig{
+		URL:     server.URL,
+		Extract: "regex",
+		Pattern: `Address: ([0-9.]+)`,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "192.0.2.3", address)
+
+	_, err = getHTTPAddress(t, SourceConfig{URL: server.URL, Pattern: `nope ([0-9.]+)`})
+	assert.Error(t, err)
+}
+
+func TestHTTPSourceConfigErrors(t *testing.T) {
+	_, err := GetSource(SourceConfig{URL: "http://ip.example.com", Extract: "json"}, IPv4)
+	assert.Error(t, err, "json without a path should fail")
+	_, err = GetSource(SourceConfig{URL: "http://ip.example.com", Pattern: "("}, IPv4)
+	assert.Error(t, err, "invalid pattern should fail")
+	_, err = GetSource(SourceConfig{URL: "http://ip.example.com", Extract: "xml"}, IPv4)
+	assert.Error(t, err, "unknown extract should fail")
+	_, err = GetSource(SourceConfig{URL: "stun://stun.example.com", Path: "ip"}, IPv4)
+	assert.Error(t, err, "non http sources do not extract")
+}
+
+func TestParseSourceConfigs(t *testing.T) {
+	configs, err := ParseSourceConfigs([]interface{}{
+		"http://ipv4.icanhazip.com",
+		map[interface{}]interface{}{
+			"url":     "https://api.ipify.org?format=json",
+			"extract": "json",
+			"path":    "ip",
+			"headers": map[interface{}]interface{}{"Accept": "application/json"},
+			"timeout": "5s",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, configs, 2)
+	assert.Equal(t, "http://ipv4.icanhazip.com", configs[0].URL)
+	assert.Equal(t, "ip", configs[1].Path)
+	assert.Equal(t, "application/json", configs[1].Headers["Accept"])
+	assert.Equal(t, 5*time.Second, configs[1].Timeout)
+
+	configs, err = ParseSourceConfigs([]string{"http://a.example.com", "http://b.example.com"})
+	assert.NoError(t, err)
+	assert.Len(t, configs, 2)
+
+	_, err = ParseSourceConfigs([]interface{}{map[string]interface{}{"path": "ip"}})
+	assert.Error(t, err, "missing url should fail")
+	_, err = ParseSourceConfigs([]interface{}{map[string]interface{}{"url": "http://a", "nope": 1}})
+	assert.Error(t, err, "unknown options should fail")
+}
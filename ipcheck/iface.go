@@ -0,0 +1,188 @@
+package ipcheck
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const interfaceScheme = "interface"
+
+// IPv6 address flags found in /proc/net/if_inet6
+const (
+	ifaFlagTemporary  = 0x01
+	ifaFlagDeprecated = 0x20
+)
+
+// privateNetworks are never considered public unless asked for with a cidr
+var privateNetworks = parseCIDRs("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16",
+	"100.64.0.0/10", "fc00::/7")
+
+// isPublicIP returns true if ip is not a loopback, link local, unspecified
+// or private address
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsLinkLocalUnicast() && !ip.IsUnspecified() &&
+		!containsIP(privateNetworks, ip)
+}
+
+// InterfaceAddress is an address assigned to a network interface
+type InterfaceAddress struct {
+	IP         net.IP
+	Temporary  bool
+	Deprecated bool
+}
+
+// addressLister lists the addresses assigned to the named interface
+type addressLister func(name string) ([]InterfaceAddress, error)
+
+// InterfaceSource gets our address from a local network interface
+type InterfaceSource struct {
+	name          string
+	family        Family
+	networks      []*net.IPNet
+	listAddresses addressLister
+}
+
+// NewInterfaceSource is InterfaceSource constructor, urls take the form
+// interface://<name>[?cidr=<cidr>[,<cidr>]]
+func NewInterfaceSource(sourceURL *url.URL, config SourceConfig, family Family) (*InterfaceSource, error) {
+	i := &InterfaceSource{}
+	i.name = sourceURL.Host
+	if i.name == "" {
+		return i, fmt.Errorf("name missing from interface source '%s'", sourceURL)
+	}
+	i.family = family
+	if cidrs := sourceURL.Query().Get("cidr"); cidrs != "" {
+		for _, cidr := range strings.Split(cidrs, ",") {
+			_, network, err := net.ParseCIDR(strings.TrimSpace(cidr))
+			if err != nil {
+				return i, fmt.Errorf("cidr '%s' is not valid in interface source", cidr)
+			}
+			i.networks = append(i.networks, network)
+		}
+	}
+	i.listAddresses = systemAddresses
+	return i, nil
+}
+
+// GetAddress returns the first usable address on the interface
+func (i *InterfaceSource) GetAddress() (string, error) {
+	addresses, err := i.listAddresses(i.name)
+	if err != nil {
+		return "", err
+	}
+	for _, address := range addresses {
+		if i.usable(address) {
+			return address.IP.String(), nil
+		}
+	}
+	return "", fmt.Errorf("no usable %s address found on %d addresses", i.family, len(addresses))
+}
+
+// usable returns true if the address is a stable public address of our family
+func (i *InterfaceSource) usable(address InterfaceAddress) bool {
+	ip := address.IP
+	if !i.family.IsValid(ip.String()) {
+		return false
+	}
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() ||
+		address.Temporary || address.Deprecated {
+		return false
+	}
+	if len(i.networks) > 0 {
+		return containsIP(i.networks, ip)
+	}
+	return !containsIP(privateNetworks, ip)
+}
+
+func (i *InterfaceSource) String() string {
+	if len(i.networks) == 0 {
+		return "interface://" + i.name
+	}
+	cidrs := make([]string, len(i.networks))
+	for idx, network := range i.networks {
+		cidrs[idx] = network.String()
+	}
+	return fmt.Sprintf("interface://%s?cidr=%s", i.name, strings.Join(cidrs, ","))
+}
+
+// systemAddresses lists the addresses of a real interface, on linux the
+// IPv6 address flags are read from /proc/net/if_inet6
+func systemAddresses(name string) ([]InterfaceAddress, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	flags := inet6Flags(name)
+
+	addresses := make([]InterfaceAddress, 0, len(addrs))
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		flag := flags[ipNet.IP.String()]
+		addresses = append(addresses, InterfaceAddress{
+			IP:         ipNet.IP,
+			Temporary:  flag&ifaFlagTemporary != 0,
+			Deprecated: flag&ifaFlagDeprecated != 0,
+		})
+	}
+	return addresses, nil
+}
+
+// inet6Flags returns the flags of each IPv6 address on the named interface,
+// an empty map is returned when they can not be read
+func inet6Flags(name string) map[string]uint64 {
+	flags := map[string]uint64{}
+	file, err := os.Open("/proc/net/if_inet6")
+	if err != nil {
+		return flags
+	}
+	defer file.Close()
+
+	// each line is '<address> <index> <prefix length> <scope> <flags> <name>'
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 6 || fields[5] != name {
+			continue
+		}
+		raw, err := hex.DecodeString(fields[0])
+		if err != nil || len(raw) != net.IPv6len {
+			continue
+		}
+		flag, err := strconv.ParseUint(fields[4], 16, 32)
+		if err != nil {
+			continue
+		}
+		flags[net.IP(raw).String()] = flag
+	}
+	return flags
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, networks[i], _ = net.ParseCIDR(cidr)
+	}
+	return networks
+}
@@ -0,0 +1,84 @@
+package ipcheck
+
+import (
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestInterfaceSource(t *testing.T, rawURL string, family Family,
+	addresses ...InterfaceAddress) *InterfaceSource {
+	sourceURL, err := url.Parse(rawURL)
+	assert.NoError(t, err)
+	src, err := NewInterfaceSource(sourceURL, SourceConfig{}, family)
+	assert.NoError(t, err)
+	src.listAddresses = func(name string) ([]InterfaceAddress, error) {
+		assert.Equal(t, "eth0", name)
+		return addresses, nil
+	}
+	return src
+}
+
+func addr(ip string) InterfaceAddress {
+	return InterfaceAddress{IP: net.ParseIP(ip)}
+}
+
+func TestInterfaceSourceConfig(t *testing.T) {
+	sourceURL, _ := url.Parse("interface://eth0?cidr=2001:db8::/32,192.0.2.0/24")
+	src, err := NewInterfaceSource(sourceURL, SourceConfig{}, IPv6)
+	assert.NoError(t, err)
+	assert.Len(t, src.networks, 2)
+	assert.Equal(t, "interface://eth0?cidr=2001:db8::/32,192.0.2.0/24", src.String())
+
+	sourceURL, _ = url.Parse("interface://eth0?cidr=nope")
+	_, err = NewInterfaceSource(sourceURL, SourceConfig{}, IPv6)
+	assert.Error(t, err)
+
+	sourceURL, _ = url.Parse("interface://")
+	_, err = NewInterfaceSource(sourceURL, SourceConfig{}, IPv6)
+	assert.Error(t, err)
+}
+
+func TestInterfaceSourceIPv6(t *testing.T) {
+	src := newTestInterfaceSource(t, "interface://eth0", IPv6,
+		addr("192.0.2.1"),
+		addr("fe80::1"),
+		addr("fd00::1"),
+		InterfaceAddress{IP: net.ParseIP("2001:db8::aaaa"), Temporary: true},
+		InterfaceAddress{IP: net.ParseIP("2001:db8::bbbb"), Deprecated: true},
+		addr("2001:db8::1"),
+	)
+	address, err := src.GetAddress()
+	assert.NoError(t, err)
+	assert.Equal(t, "2001:db8::1", address)
+}
+
+func TestInterfaceSourceIPv4(t *testing.T) {
+	src := newTestInterfaceSource(t, "interface://eth0", IPv4,
+		addr("127.0.0.1"), addr("192.168.1.10"), addr("169.254.1.1"), addr("198.51.100.7"))
+	address, err := src.GetAddress()
+	assert.NoError(t, err)
+	assert.Equal(t, "198.51.100.7", address)
+}
+
+func TestInterfaceSourceCIDR(t *testing.T) {
+	src := newTestInterfaceSource(t, "interface://eth0?cidr=2001:db8:2::/48", IPv6,
+		addr("2001:db8:1::1"), addr("2001:db8:2::1"))
+	address, err := src.GetAddress()
+	assert.NoError(t, err)
+	assert.Equal(t, "2001:db8:2::1", address)
+
+	src = newTestInterfaceSource(t, "interface://eth0?cidr=10.0.0.0/8", IPv4,
+		addr("10.1.2.3"))
+	address, err = src.GetAddress()
+	assert.NoError(t, err)
+	assert.Equal(t, "10.1.2.3", address, "private addresses can be asked for with a cidr")
+}
+
+func TestInterfaceSourceNoAddress(t *testing.T) {
+	src := newTestInterfaceSource(t, "interface://eth0", IPv6, addr("fe80::1"))
+	_, err := src.GetAddress()
+	assert.Error(t, err)
+}
@@ -0,0 +1,213 @@
+package ipcheck
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var log = logrus.New()
+
+// Observer is told the outcome of every address check, ie. to collect metrics
+type Observer func(source Source, family Family, duration time.Duration, err error)
+
+var observer Observer
+
+// Family is the IP address family to look up
+type Family int
+
+// Supported address families
+const (
+	IPv4 Family = 4
+	IPv6 Family = 6
+)
+
+// Source is a way to discover our public address
+type Source interface {
+	GetAddress() (string, error)
+	String() string
+}
+
+// SourceConfig is the config for an address source, only the url is
+// required and the other options are used by http sources
+type SourceConfig struct {
+	URL     string            `mapstructure:"url"`
+	Extract string            `mapstructure:"extract"`
+	Path    string            `mapstructure:"path"`
+	Pattern string            `mapstructure:"pattern"`
+	Headers map[string]string `mapstructure:"headers"`
+	Timeout time.Duration     `mapstructure:"timeout"`
+}
+
+// GetSource returns an address source from a given config, the url scheme
+// selects the type of source
+func GetSource(config SourceConfig, family Family) (src Source, err error) {
+	sourceURL, err := url.Parse(strings.TrimSpace(config.URL))
+	if err != nil {
+		err = errors.Wrapf(err, "ip source '%s' is not a valid url", config.URL)
+		return
+	}
+	scheme := strings.ToLower(sourceURL.Scheme)
+	if scheme != httpScheme && scheme != httpsScheme &&
+		(config.Extract != "" || config.Path != "" || config.Pattern != "" || len(config.Headers) > 0) {
+		err = errors.Errorf("ip source '%s' only supports the url and timeout options", config.URL)
+		return
+	}
+	switch scheme {
+	case httpScheme, httpsScheme:
+		src, err = NewHTTPSource(sourceURL, config, family)
+	case dnsScheme:
+		src, err = NewDNSSource(sourceURL, config, family)
+	case stunScheme:
+		src, err = NewSTUNSource(sourceURL, config, family)
+	case interfaceScheme:
+		src, err = NewInterfaceSource(sourceURL, config, family)
+	case gatewayScheme:
+		src, err = NewGatewaySource(sourceURL, config, family)
+	default:
+		err = errors.Errorf("ip source type '%s' not recognized", sourceURL.Scheme)
+	}
+	return
+}
+
+// GetSources returns the address sources for a list of configs
+func GetSources(configs []SourceConfig, family Family) ([]Source, error) {
+	sources := make([]Source, 0, len(configs))
+	for _, config := range configs {
+		src, err := GetSource(config, family)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, src)
+	}
+	return sources, nil
+}
+
+// ParseSourceConfigs reads a list of sources from the raw config value,
+// each entry can be a bare url or a map of options
+func ParseSourceConfigs(raw interface{}) ([]SourceConfig, error) {
+	var entries []interface{}
+	switch value := raw.(type) {
+	case nil:
+	case string:
+		for _, field := range strings.Fields(value) {
+			entries = append(entries, field)
+		}
+	case []string:
+		for _, entry := range value {
+			entries = append(entries, entry)
+		}
+	case []interface{}:
+		entries = value
+	default:
+		return nil, errors.Errorf("ip sources must be a list, not %T", raw)
+	}
+
+	configs := make([]SourceConfig, 0, len(entries))
+	for _, entry := range entries {
+		config := SourceConfig{}
+		if rawURL, ok := entry.(string); ok {
+			config.URL = rawURL
+		} else {
+			decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+				DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+				ErrorUnused:      true,
+				WeaklyTypedInput: true,
+				Result:           &config,
+			})
+			if err != nil {
+				return nil, err
+			}
+			if err = decoder.Decode(entry); err != nil {
+				return nil, errors.Wrap(err, "could not parse ip source")
+			}
+		}
+		if config.URL == "" {
+			return nil, errors.New("ip source is missing a url")
+		}
+		configs = append(configs, config)
+	}
+	return configs, nil
+}
+
+// Lookup tries random sources until one returns a valid address, or
+// maxAttempts is reached
+func Lookup(sources []Source, family Family, maxAttempts int) (ipAddress string, err error) {
+	prefix := family.logPrefix()
+	if len(sources) == 0 {
+		return "", fmt.Errorf("%s no ip sources configured", prefix)
+	}
+	rand.Seed(time.Now().Unix())
+	gotIP := false
+
+	for i := 0; i < maxAttempts && !gotIP; i++ {
+		src := sources[rand.Intn(len(sources))]
+		log.Infof("%s using '%s' for ip check", prefix, src)
+
+		address, serr := checkAddress(src, family)
+		if serr != nil {
+			log.Errorf("%s Failed to get ip from '%s'", prefix, src)
+			log.Errorf("%s err=%s", prefix, serr)
+			continue
+		}
+		ipAddress = address
+		gotIP = true
+	}
+	if !gotIP {
+		err = fmt.Errorf("%s ran out of attempts to get IP address", prefix)
+	}
+
+	log.Infof("%s got public IP address=%s", prefix, ipAddress)
+	return ipAddress, err
+}
+
+// IsValid returns true if address is a valid address of this family
+func (f Family) IsValid(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	if f == IPv4 {
+		return ip.To4() != nil
+	}
+	return ip.To4() == nil && ip.To16() != nil
+}
+
+func (f Family) String() string {
+	return fmt.Sprintf("IPv%d", f)
+}
+
+func (f Family) logPrefix() string {
+	return fmt.Sprintf("ipchk%d:", f)
+}
+
+// checkAddress gets the address from src and makes sure it is a valid
+// family address
+func checkAddress(src Source, family Family) (string, error) {
+	start := time.Now()
+	address, err := src.GetAddress()
+	if err == nil && !family.IsValid(address) {
+		err = fmt.Errorf("response is not a valid %s address. response='%s'", family, address)
+	}
+	if observer != nil {
+		observer(src, family, time.Since(start), err)
+	}
+	return address, err
+}
+
+// SetObserver sets the function told about every address check
+func SetObserver(o Observer) {
+	observer = o
+}
+
+// IntializeLogging sets the logger to use in this library
+func IntializeLogging(logger *logrus.Logger) {
+	log = logger
+}
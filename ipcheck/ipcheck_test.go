@@ -0,0 +1,81 @@
+package ipcheck
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSource struct {
+	address string
+	err     error
+	calls   int
+}
+
+func (f *fakeSource) GetAddress() (string, error) {
+	f.calls++
+	return f.address, f.err
+}
+
+func (f *fakeSource) String() string {
+	return "fake://" + f.address
+}
+
+func TestGetSource(t *testing.T) {
+	src, err := GetSource(SourceConfig{URL: "http://ipv4.icanhazip.com"}, IPv4)
+	assert.NoError(t, err)
+	assert.IsType(t, &HTTPSource{}, src)
+
+	src, err = GetSource(SourceConfig{URL: "dns://resolver1.opendns.com/myip.opendns.com"}, IPv4)
+	assert.NoError(t, err)
+	assert.IsType(t, &DNSSource{}, src)
+
+	_, err = GetSource(SourceConfig{URL: "gopher://ipv4.icanhazip.com"}, IPv4)
+	assert.Error(t, err)
+}
+
+func TestFamilyIsValid(t *testing.T) {
+	assert.True(t, IPv4.IsValid("192.0.2.1"))
+	assert.False(t, IPv4.IsValid("2001:db8::1"))
+	assert.False(t, IPv4.IsValid("<html>"))
+	assert.True(t, IPv6.IsValid("2001:db8::1"))
+	assert.False(t, IPv6.IsValid("192.0.2.1"))
+}
+
+func TestLookup(t *testing.T) {
+	src := &fakeSource{address: "192.0.2.1"}
+	address, err := Lookup([]Source{src}, IPv4, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "192.0.2.1", address)
+	assert.Equal(t, 1, src.calls)
+}
+
+func TestLookupAttempts(t *testing.T) {
+	src := &fakeSource{err: errors.New("fake failure")}
+	_, err := Lookup([]Source{src}, IPv4, 3)
+	assert.Error(t, err)
+	assert.Equal(t, 3, src.calls)
+
+	src = &fakeSource{address: "192.0.2.1"}
+	_, err = Lookup([]Source{src}, IPv6, 3)
+	assert.Error(t, err, "wrong address family should not be accepted")
+
+	_, err = Lookup([]Source{}, IPv4, 3)
+	assert.Error(t, err)
+}
+
+func TestLookupObserver(t *testing.T) {
+	var checks []error
+	SetObserver(func(source Source, family Family, duration time.Duration, err error) {
+		assert.Equal(t, IPv6, family)
+		checks = append(checks, err)
+	})
+	defer SetObserver(nil)
+
+	_, err := Lookup([]Source{&fakeSource{address: "192.0.2.1"}}, IPv6, 2)
+	assert.Error(t, err)
+	assert.Len(t, checks, 2)
+	assert.Error(t, checks[0], "invalid addresses should be reported as failures")
+}
@@ -0,0 +1,111 @@
+package ipcheck
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// NAT-PMP (RFC 6886) and PCP (RFC 6887) values
+const (
+	natpmpVersion        = 0
+	natpmpOpExternalAddr = 0
+	natpmpResponseLength = 12
+	pcpVersion           = 2
+	pcpOpMap             = 1
+	pcpRequestLength     = 60
+	pcpResponseLength    = 60
+	pcpProtocolUDP       = 17
+	pcpMapLifetime       = 60
+)
+
+// getNATPMPAddress asks a NAT-PMP gateway for its external address
+func getNATPMPAddress(gateway string, timeout time.Duration) (string, error) {
+	response, err := exchangeUDP(gateway, []byte{natpmpVersion, natpmpOpExternalAddr}, timeout)
+	if err != nil {
+		return "", err
+	}
+	if len(response) < natpmpResponseLength || response[0] != natpmpVersion ||
+		response[1] != 128+natpmpOpExternalAddr {
+		return "", errors.New("invalid nat-pmp response")
+	}
+	if result := binary.BigEndian.Uint16(response[2:4]); result != 0 {
+		return "", fmt.Errorf("nat-pmp gateway responded with result code %d", result)
+	}
+	return net.IP(response[8:12]).String(), nil
+}
+
+// getPCPAddress requests a short lived PCP mapping, and returns the
+// external address assigned to it
+func getPCPAddress(gateway string, timeout time.Duration) (string, error) {
+	conn, err := net.Dial("udp", gateway)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	local := conn.LocalAddr().(*net.UDPAddr)
+
+	request := make([]byte, pcpRequestLength)
+	request[0] = pcpVersion
+	request[1] = pcpOpMap
+	binary.BigEndian.PutUint32(request[4:8], pcpMapLifetime)
+	copy(request[8:24], local.IP.To16())
+	// the map opcode data starts with a random nonce
+	if _, err = rand.Read(request[24:36]); err != nil {
+		return "", err
+	}
+	request[36] = pcpProtocolUDP
+	binary.BigEndian.PutUint16(request[40:42], uint16(local.Port))
+
+	response, err := exchangeConn(conn, request, timeout)
+	if err != nil {
+		return "", err
+	}
+	if len(response) < pcpResponseLength || response[0] != pcpVersion ||
+		response[1] != 0x80|pcpOpMap {
+		return "", errors.New("invalid pcp response")
+	}
+	if result := response[3]; result != 0 {
+		return "", fmt.Errorf("pcp gateway responded with result code %d", result)
+	}
+	if string(response[24:36]) != string(request[24:36]) {
+		return "", errors.New("pcp response does not match the request")
+	}
+
+	// we only wanted the address, so release the mapping
+	binary.BigEndian.PutUint32(request[4:8], 0)
+	conn.Write(request)
+
+	return net.IP(response[44:60]).String(), nil
+}
+
+func exchangeUDP(address string, request []byte, timeout time.Duration) ([]byte, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return exchangeConn(conn, request, timeout)
+}
+
+// exchangeConn sends request and waits for a response, resending a few
+// times since udp is unreliable
+func exchangeConn(conn net.Conn, request []byte, timeout time.Duration) ([]byte, error) {
+	response := make([]byte, 1100)
+	var err error
+	for i := 0; i < 3; i++ {
+		if _, err = conn.Write(request); err != nil {
+			return nil, err
+		}
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		var n int
+		n, err = conn.Read(response)
+		if err == nil {
+			return response[:n], nil
+		}
+	}
+	return nil, err
+}
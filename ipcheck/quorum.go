@@ -0,0 +1,73 @@
+package ipcheck
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// QuorumLookup queries size random sources in parallel, and returns the
+// address only when at least minAgree of them return it and no other
+// address got as many answers
+func QuorumLookup(sources []Source, family Family, size int, minAgree int) (string, error) {
+	prefix := family.logPrefix()
+	if len(sources) < size {
+		return "", fmt.Errorf("%s quorum needs %d ip sources, only %d configured",
+			prefix, size, len(sources))
+	}
+	rand.Seed(time.Now().Unix())
+	picked := make([]Source, size)
+	for i, idx := range rand.Perm(len(sources))[:size] {
+		picked[i] = sources[idx]
+	}
+
+	answers := make([]string, size)
+	var wg sync.WaitGroup
+	for i, src := range picked {
+		wg.Add(1)
+		go func(i int, src Source) {
+			defer wg.Done()
+			address, err := checkAddress(src, family)
+			if err != nil {
+				log.Errorf("%s Failed to get ip from '%s' err=%s", prefix, src, err)
+				return
+			}
+			answers[i] = address
+		}(i, src)
+	}
+	wg.Wait()
+
+	votes := map[string]int{}
+	winner := ""
+	for _, address := range answers {
+		if address == "" {
+			continue
+		}
+		votes[address]++
+		if votes[address] > votes[winner] {
+			winner = address
+		}
+	}
+	if len(votes) > 1 {
+		for i, address := range answers {
+			if address != "" && address != winner {
+				log.Warnf("%s '%s' disagrees with the majority, address=%s majority=%s",
+					prefix, picked[i], address, winner)
+			}
+		}
+	}
+	if winner == "" || votes[winner] < minAgree {
+		return "", fmt.Errorf("%s no quorum, %d of %d sources agreed on an address, %d needed",
+			prefix, votes[winner], size, minAgree)
+	}
+	for address, count := range votes {
+		if address != winner && count == votes[winner] {
+			return "", fmt.Errorf("%s no quorum, %d sources agreed on %s and %d on %s",
+				prefix, votes[winner], winner, count, address)
+		}
+	}
+
+	log.Infof("%s got public IP address=%s from %d of %d sources", prefix, winner, votes[winner], size)
+	return winner, nil
+}
@@ -0,0 +1,54 @@
+package ipcheck
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuorumLookup(t *testing.T) {
+	sources := []Source{
+		&fakeSource{address: "192.0.2.1"},
+		&fakeSource{address: "192.0.2.1"},
+		&fakeSource{address: "198.51.100.1"},
+	}
+	address, err := QuorumLookup(sources, IPv4, 3, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "192.0.2.1", address)
+	for _, src := range sources {
+		assert.Equal(t, 1, src.(*fakeSource).calls)
+	}
+}
+
+func TestQuorumLookupNoQuorum(t *testing.T) {
+	sources := []Source{
+		&fakeSource{address: "192.0.2.1"},
+		&fakeSource{address: "198.51.100.1"},
+		&fakeSource{err: errors.New("fake failure")},
+	}
+	_, err := QuorumLookup(sources, IPv4, 3, 2)
+	assert.Error(t, err)
+
+	sources = []Source{
+		&fakeSource{address: "<html>captive portal</html>"},
+		&fakeSource{address: "<html>captive portal</html>"},
+	}
+	_, err = QuorumLookup(sources, IPv4, 2, 2)
+	assert.Error(t, err, "invalid addresses should not count")
+
+	sources = []Source{
+		&fakeSource{address: "192.0.2.1"},
+		&fakeSource{address: "198.51.100.1"},
+		&fakeSource{address: "192.0.2.1"},
+		&fakeSource{address: "198.51.100.1"},
+	}
+	_, err = QuorumLookup(sources, IPv4, 4, 2)
+	assert.Error(t, err, "a tie is not a quorum")
+}
+
+func TestQuorumLookupSize(t *testing.T) {
+	sources := []Source{&fakeSource{address: "192.0.2.1"}}
+	_, err := QuorumLookup(sources, IPv4, 2, 2)
+	assert.Error(t, err)
+}
@@ -0,0 +1,189 @@
+package ipcheck
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+)
+
+const stunScheme = "stun"
+
+const stunDefaultPort = "3478"
+
+// STUN message values from RFC 5389
+const (
+	stunBindingRequest  = 0x0001
+	stunBindingSuccess  = 0x0101
+	stunMagicCookie     = 0x2112A442
+	stunHeaderLength    = 20
+	stunMappedAddress   = 0x0001
+	stunXorMappedAddr   = 0x0020
+	stunFamilyIPv4      = 0x01
+	stunFamilyIPv6      = 0x02
+	stunMaxResponseSize = 1500
+)
+
+// STUNSource gets our address from the mapped address in the response
+// to a STUN binding request
+type STUNSource struct {
+	server   string
+	network  string
+	timeout  time.Duration
+	attempts int
+}
+
+// NewSTUNSource is STUNSource constructor, urls take the form
+// stun://<server>[:port]
+func NewSTUNSource(sourceURL *url.URL, config SourceConfig, family Family) (*STUNSource, error) {
+	s := &STUNSource{}
+	if sourceURL.Opaque != "" {
+		// stun:<server>:<port> style urls are opaque
+		parsed, err := url.Parse("stun://" + sourceURL.Opaque)
+		if err != nil {
+			return s, fmt.Errorf("stun source '%s' is not a valid url", sourceURL)
+		}
+		sourceURL = parsed
+	}
+	host := sourceURL.Hostname()
+	if host == "" {
+		return s, fmt.Errorf("server missing from stun source '%s'", sourceURL)
+	}
+	port := sourceURL.Port()
+	if port == "" {
+		port = stunDefaultPort
+	}
+	s.server = net.JoinHostPort(host, port)
+	s.network = "udp4"
+	if family == IPv6 {
+		s.network = "udp6"
+	}
+	s.timeout = 3 * time.Second
+	if config.Timeout > 0 {
+		s.timeout = config.Timeout
+	}
+	s.attempts = 3
+	return s, nil
+}
+
+// GetAddress sends a binding request and returns the mapped address
+func (s *STUNSource) GetAddress() (string, error) {
+	conn, err := net.Dial(s.network, s.server)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	request, transactionID, err := newSTUNBindingRequest()
+	if err != nil {
+		return "", err
+	}
+	response := make([]byte, stunMaxResponseSize)
+	// udp is unreliable, so resend the request a few times
+	for i := 0; i < s.attempts; i++ {
+		if _, err = conn.Write(request); err != nil {
+			return "", err
+		}
+		conn.SetReadDeadline(time.Now().Add(s.timeout))
+		var n int
+		n, err = conn.Read(response)
+		if err != nil {
+			continue
+		}
+		return parseSTUNBindingResponse(response[:n], transactionID)
+	}
+	return "", err
+}
+
+func (s *STUNSource) String() string {
+	return "stun://" + s.server
+}
+
+func newSTUNBindingRequest() ([]byte, []byte, error) {
+	request := make([]byte, stunHeaderLength)
+	binary.BigEndian.PutUint16(request[0:2], stunBindingRequest)
+	binary.BigEndian.PutUint16(request[2:4], 0)
+	binary.BigEndian.PutUint32(request[4:8], stunMagicCookie)
+	if _, err := rand.Read(request[8:20]); err != nil {
+		return nil, nil, err
+	}
+	return request, request[8:20], nil
+}
+
+func parseSTUNBindingResponse(response []byte, transactionID []byte) (string, error) {
+	if len(response) < stunHeaderLength {
+		return "", errors.New("stun response is too short")
+	}
+	if binary.BigEndian.Uint16(response[0:2]) != stunBindingSuccess {
+		return "", fmt.Errorf("unexpected stun response type 0x%04x",
+			binary.BigEndian.Uint16(response[0:2]))
+	}
+	if binary.BigEndian.Uint32(response[4:8]) != stunMagicCookie ||
+		string(response[8:20]) != string(transactionID) {
+		return "", errors.New("stun response does not match the request")
+	}
+	length := int(binary.BigEndian.Uint16(response[2:4]))
+	if stunHeaderLength+length > len(response) {
+		return "", errors.New("stun response is truncated")
+	}
+
+	var mapped net.IP
+	attributes := response[stunHeaderLength : stunHeaderLength+length]
+	for len(attributes) >= 4 {
+		attrType := binary.BigEndian.Uint16(attributes[0:2])
+		attrLength := int(binary.BigEndian.Uint16(attributes[2:4]))
+		if 4+attrLength > len(attributes) {
+			break
+		}
+		value := attributes[4 : 4+attrLength]
+		switch attrType {
+		case stunXorMappedAddr:
+			// the xor mapped address is preferred, return it right away
+			return decodeSTUNAddress(value, response[4:20])
+		case stunMappedAddress:
+			if ip, err := decodeSTUNAddress(value, nil); err == nil {
+				mapped = net.ParseIP(ip)
+			}
+		}
+		// attributes are padded to 4 bytes
+		padded := (attrLength + 3) &^ 3
+		if 4+padded > len(attributes) {
+			break
+		}
+		attributes = attributes[4+padded:]
+	}
+	if mapped != nil {
+		return mapped.String(), nil
+	}
+	return "", errors.New("no mapped address found in stun response")
+}
+
+// decodeSTUNAddress decodes an address attribute, when key is given the
+// address is xor'd with it (the magic cookie and transaction id)
+func decodeSTUNAddress(value []byte, key []byte) (string, error) {
+	if len(value) < 4 {
+		return "", errors.New("stun address is too short")
+	}
+	var ip net.IP
+	switch value[1] {
+	case stunFamilyIPv4:
+		ip = make(net.IP, net.IPv4len)
+	case stunFamilyIPv6:
+		ip = make(net.IP, net.IPv6len)
+	default:
+		return "", fmt.Errorf("unknown stun address family 0x%02x", value[1])
+	}
+	if len(value) < 4+len(ip) {
+		return "", errors.New("stun address is too short")
+	}
+	copy(ip, value[4:4+len(ip)])
+	if key != nil {
+		for i := range ip {
+			ip[i] ^= key[i]
+		}
+	}
+	return ip.String(), nil
+}
@@ -0,0 +1,94 @@
+package ipcheck
+
+import (
+	"encoding/binary"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newSTUNResponse builds a binding response to request with the given
+// address attribute
+func newSTUNResponse(request []byte, attrType uint16, ip net.IP) []byte {
+	family := byte(stunFamilyIPv4)
+	address := ip.To4()
+	if address == nil {
+		family = stunFamilyIPv6
+		address = ip.To16()
+	}
+	value := append([]byte{0, family, 0, 0}, address...)
+	if attrType == stunXorMappedAddr {
+		for i := range address {
+			value[4+i] ^= request[4+i]
+		}
+	}
+
+	response := make([]byte, stunHeaderLength, stunHeaderLength+4+len(value))
+	copy(response, request)
+	binary.BigEndian.PutUint16(response[0:2], stunBindingSuccess)
+	binary.BigEndian.PutUint16(response[2:4], uint16(4+len(value)))
+	attr := make([]byte, 4)
+	binary.BigEndian.PutUint16(attr[0:2], attrType)
+	binary.BigEndian.PutUint16(attr[2:4], uint16(len(value)))
+	response = append(response, attr...)
+	return append(response, value...)
+}
+
+func TestSTUNSourceConfig(t *testing.T) {
+	sourceURL, _ := url.Parse("stun:stun.l.google.com:19302")
+	src, err := NewSTUNSource(sourceURL, SourceConfig{}, IPv4)
+	assert.NoError(t, err)
+	assert.Equal(t, "stun.l.google.com:19302", src.server)
+
+	sourceURL, _ = url.Parse("stun://stun.example.com")
+	src, err = NewSTUNSource(sourceURL, SourceConfig{}, IPv6)
+	assert.NoError(t, err)
+	assert.Equal(t, "stun.example.com:3478", src.server)
+	assert.Equal(t, "udp6", src.network)
+}
+
+func TestSTUNParseResponse(t *testing.T) {
+	request, transactionID, err := newSTUNBindingRequest()
+	assert.NoError(t, err)
+
+	for _, address := range []string{"192.0.2.1", "2001:db8::1"} {
+		response := newSTUNResponse(request, stunXorMappedAddr, net.ParseIP(address))
+		ip, err := parseSTUNBindingResponse(response, transactionID)
+		assert.NoError(t, err)
+		assert.Equal(t, address, ip)
+
+		response = newSTUNResponse(request, stunMappedAddress, net.ParseIP(address))
+		ip, err = parseSTUNBindingResponse(response, transactionID)
+		assert.NoError(t, err)
+		assert.Equal(t, address, ip)
+	}
+
+	response := newSTUNResponse(request, stunXorMappedAddr, net.ParseIP("192.0.2.1"))
+	_, err = parseSTUNBindingResponse(response, make([]byte, 12))
+	assert.Error(t, err, "mismatched transaction id should fail")
+	_, err = parseSTUNBindingResponse(response[:10], transactionID)
+	assert.Error(t, err, "short response should fail")
+}
+
+func TestSTUNSource(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer conn.Close()
+	go func() {
+		buf := make([]byte, stunMaxResponseSize)
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		conn.WriteTo(newSTUNResponse(buf[:n], stunXorMappedAddr, addr.(*net.UDPAddr).IP), addr)
+	}()
+
+	sourceURL, _ := url.Parse("stun://" + conn.LocalAddr().String())
+	src, err := NewSTUNSource(sourceURL, SourceConfig{}, IPv4)
+	assert.NoError(t, err)
+	address, err := src.GetAddress()
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", address)
+}
@@ -0,0 +1,160 @@
+package ipcheck
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const ssdpAddress = "239.255.255.250:1900"
+
+// upnpServiceTypes are the IGD services that can give the WAN address
+var upnpServiceTypes = []string{
+	"urn:schemas-upnp-org:service:WANIPConnection:2",
+	"urn:schemas-upnp-org:service:WANIPConnection:1",
+	"urn:schemas-upnp-org:service:WANPPPConnection:1",
+}
+
+// upnpDevice is the part of a device description we need
+type upnpDevice struct {
+	URLBase string `xml:"URLBase"`
+	Device  struct {
+		Services []upnpService `xml:"serviceList>service"`
+		Devices  []upnpNested  `xml:"deviceList>device"`
+	} `xml:"device"`
+}
+
+type upnpNested struct {
+	Services []upnpService `xml:"serviceList>service"`
+	Devices  []upnpNested  `xml:"deviceList>device"`
+}
+
+type upnpService struct {
+	ServiceType string `xml:"serviceType"`
+	ControlURL  string `xml:"controlURL"`
+}
+
+// discoverIGD sends an SSDP search and returns the location of the first
+// gateway device that answers
+func discoverIGD(timeout time.Duration) (string, error) {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	addr, err := net.ResolveUDPAddr("udp4", ssdpAddress)
+	if err != nil {
+		return "", err
+	}
+
+	search := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: " + ssdpAddress + "\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 2\r\n" +
+		"ST: urn:schemas-upnp-org:device:InternetGatewayDevice:1\r\n\r\n"
+	if _, err = conn.WriteTo([]byte(search), addr); err != nil {
+		return "", err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return "", errors.New("no gateway device answered the ssdp search")
+		}
+		res, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		if location := res.Header.Get("Location"); location != "" {
+			return location, nil
+		}
+	}
+}
+
+// getUPnPExternalAddress reads the device description at location and
+// calls GetExternalIPAddress on its WAN connection service
+func getUPnPExternalAddress(location string, timeout time.Duration) (string, error) {
+	client := &http.Client{Timeout: timeout}
+	res, err := client.Get(location)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	device := upnpDevice{}
+	if err = xml.NewDecoder(res.Body).Decode(&device); err != nil {
+		return "", err
+	}
+
+	service, ok := findUPnPService(device.Device.Services, device.Device.Devices)
+	if !ok {
+		return "", errors.New("gateway device has no WAN connection service")
+	}
+	base := device.URLBase
+	if base == "" {
+		base = location
+	}
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	controlURL, err := baseURL.Parse(service.ControlURL)
+	if err != nil {
+		return "", err
+	}
+
+	body := fmt.Sprintf(`<?xml version="1.0"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+<s:Body><u:GetExternalIPAddress xmlns:u="%s"/></s:Body>
+</s:Envelope>`, service.ServiceType)
+	req, err := http.NewRequest(http.MethodPost, controlURL.String(), strings.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", fmt.Sprintf(`"%s#GetExternalIPAddress"`, service.ServiceType))
+	soapRes, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer soapRes.Body.Close()
+	if soapRes.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("gateway responded with %s", soapRes.Status)
+	}
+
+	envelope := struct {
+		Address string `xml:"Body>GetExternalIPAddressResponse>NewExternalIPAddress"`
+	}{}
+	if err = xml.NewDecoder(soapRes.Body).Decode(&envelope); err != nil {
+		return "", err
+	}
+	if envelope.Address == "" {
+		return "", errors.New("gateway did not return an address")
+	}
+	return strings.TrimSpace(envelope.Address), nil
+}
+
+// findUPnPService searches the device tree for a WAN connection service
+func findUPnPService(services []upnpService, devices []upnpNested) (upnpService, bool) {
+	for _, serviceType := range upnpServiceTypes {
+		for _, service := range services {
+			if service.ServiceType == serviceType {
+				return service, true
+			}
+		}
+	}
+	for _, device := range devices {
+		if service, ok := findUPnPService(device.Services, device.Devices); ok {
+			return service, true
+		}
+	}
+	return upnpService{}, false
+}
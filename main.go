@@ -1,14 +1,21 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"path"
 	"runtime"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gesquive/dyngo/dns"
+	"github.com/gesquive/dyngo/ipcheck"
+	"github.com/gesquive/dyngo/mqtt"
+	"github.com/gesquive/dyngo/notify"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -65,6 +72,9 @@ func init() {
 	RootCmd.PersistentFlags().BoolP("run-once", "o", false,
 		"Only run once and exit")
 
+	RootCmd.PersistentFlags().Bool("dry-run", false,
+		"Show what a sync would change without changing anything, and exit")
+
 	RootCmd.PersistentFlags().BoolP("ipv4", "4", true,
 		"Check for our WAN IPv4 address")
 	RootCmd.PersistentFlags().BoolP("ipv6", "6", true,
@@ -73,6 +83,16 @@ func init() {
 	RootCmd.PersistentFlags().StringP("sync-interval", "i", "60m",
 		"The duration between DNS updates")
 
+	RootCmd.PersistentFlags().String("state-file", "",
+		"Path to a file to keep the last synced addresses in")
+	RootCmd.PersistentFlags().String("verify-interval", "24h",
+		"The duration between full checks of every record")
+
+	RootCmd.PersistentFlags().Bool("watch-config", false,
+		"Reload the config when the config file changes")
+	RootCmd.PersistentFlags().String("http-listen", "",
+		"The address to serve metrics and status on, disabled if empty")
+
 	RootCmd.PersistentFlags().BoolVarP(&debug, "debug", "D", false,
 		"Include debug statements in log output")
 	RootCmd.PersistentFlags().MarkHidden("debug")
@@ -82,21 +102,49 @@ func init() {
 	viper.BindEnv("config")
 	viper.BindEnv("log-file")
 	viper.BindEnv("run-once")
+	viper.BindEnv("dry-run")
 	viper.BindEnv("sync-interval")
+	viper.BindEnv("state-file")
+	viper.BindEnv("verify-interval")
+	viper.BindEnv("watch-config")
+	viper.BindEnv("http-listen")
 	viper.BindEnv("ipv4")
 	viper.BindEnv("ipv6")
 
 	viper.BindPFlag("config", RootCmd.PersistentFlags().Lookup("config"))
 	viper.BindPFlag("log_file", RootCmd.PersistentFlags().Lookup("log-file"))
 	viper.BindPFlag("service.run_once", RootCmd.PersistentFlags().Lookup("run-once"))
+	viper.BindPFlag("service.dry_run", RootCmd.PersistentFlags().Lookup("dry-run"))
 	viper.BindPFlag("service.sync_interval", RootCmd.PersistentFlags().Lookup("sync-interval"))
+	viper.BindPFlag("service.state_file", RootCmd.PersistentFlags().Lookup("state-file"))
+	viper.BindPFlag("service.verify_interval", RootCmd.PersistentFlags().Lookup("verify-interval"))
+	viper.BindPFlag("service.watch_config", RootCmd.PersistentFlags().Lookup("watch-config"))
+	viper.BindPFlag("http.listen", RootCmd.PersistentFlags().Lookup("http-listen"))
 	viper.BindPFlag("ip_check.ipv4", RootCmd.PersistentFlags().Lookup("ipv4"))
 	viper.BindPFlag("ip_check.ipv6", RootCmd.PersistentFlags().Lookup("ipv6"))
 
 	viper.SetDefault("log_file", "/var/log/dyngo.log")
 	viper.SetDefault("service.sync_interval", "60m")
+	viper.SetDefault("service.verify_interval", "24h")
+	viper.SetDefault("service.state_file", "")
+	viper.SetDefault("service.workers", 4)
+	viper.SetDefault("service.provider_timeout", "60s")
+	viper.SetDefault("service.shutdown_timeout", "30s")
+	viper.SetDefault("http.listen", "")
+	viper.SetDefault("http.ready_intervals", 3)
+	viper.SetDefault("http.token", "")
+	viper.SetDefault("http.socket", "")
+	viper.SetDefault("service.retry.attempts", 3)
+	viper.SetDefault("service.retry.initial_backoff", "10s")
+	viper.SetDefault("service.retry.max_backoff", "5m")
+	viper.SetDefault("service.retry.jitter", 0.2)
+	viper.SetDefault("notifications.remind_interval", "24h")
+	viper.SetDefault("notifications.timeout", "10s")
+	viper.SetDefault("hooks.timeout", "30s")
 	viper.SetDefault("ip_check.ipv4_urls", []string{})
 	viper.SetDefault("ip_check.ipv6_urls", []string{})
+	viper.SetDefault("ip_check.quorum.sources", 0)
+	viper.SetDefault("ip_check.quorum.min_agree", 0)
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -151,17 +199,9 @@ func preRun(cmd *cobra.Command, args []string) {
 }
 
 func run(cmd *cobra.Command, args []string) {
-	logFilePath := getLogFilePath(viper.GetString("log_file"))
-	log.Debugf("config: log_file=%s", logFilePath)
-	if strings.ToLower(logFilePath) == "stdout" || logFilePath == "" || logFilePath == "-" {
-		log.SetOutput(os.Stdout)
-	} else {
-		logFile, err := os.OpenFile(logFilePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
-		if err != nil {
-			log.Fatalf("error opening log file=%v", err)
-		}
+	logFile := openLogFile()
+	if logFile != nil {
 		defer logFile.Close()
-		log.SetOutput(logFile)
 	}
 
 	log.Debugf("config: file=%s", viper.ConfigFileUsed())
@@ -173,14 +213,24 @@ func run(cmd *cobra.Command, args []string) {
 		os.Exit(2)
 	}
 
+	if err := validateConfig(); err != nil {
+		log.Errorf("config: %v", err)
+		os.Exit(1)
+	}
 	if checkIPv4 {
-		log.Debugf("config: ipv4_urls=%q", viper.GetStringSlice("ip_check.ipv4_urls"))
+		log.Debugf("config: ipv4_urls=%q", viper.Get("ip_check.ipv4_urls"))
 	}
 	if checkIPv6 {
-		log.Debugf("config: ipv6_urls=%q", viper.GetStringSlice("ip_check.ipv6_urls"))
+		log.Debugf("config: ipv6_urls=%q", viper.Get("ip_check.ipv6_urls"))
+	}
+	if viper.GetInt("ip_check.quorum.sources") > 0 {
+		log.Debugf("config: quorum sources=%d min_agree=%d",
+			viper.GetInt("ip_check.quorum.sources"), viper.GetInt("ip_check.quorum.min_agree"))
 	}
 
 	dns.IntializeLogging(log)
+	ipcheck.IntializeLogging(log)
+	ipcheck.SetObserver(observeIPCheck)
 	dnsProviders, err := getDNSProviders()
 	if err != nil {
 		log.Errorf("could not parse dns_providers: %v", err)
@@ -191,8 +241,49 @@ func run(cmd *cobra.Command, args []string) {
 		os.Exit(5)
 	}
 
+	if viper.GetBool("service.dry_run") {
+		ctx, cancel := shutdownContext()
+		ok := RunPlan(ctx, dnsProviders, os.Stdout)
+		cancel()
+		if !ok {
+			os.Exit(1)
+		}
+		return
+	}
+
+	notify.IntializeLogging(log)
+	mqtt.IntializeLogging(log)
+	if err := configureMQTT(); err != nil {
+		log.Errorf("could not parse mqtt: %v", err)
+		os.Exit(1)
+	}
+	if err := configureNotifications(); err != nil {
+		log.Errorf("could not parse notifications: %v", err)
+		os.Exit(1)
+	}
+	if err := configureHooks(); err != nil {
+		log.Errorf("could not parse hooks: %v", err)
+		os.Exit(1)
+	}
+
+	statePath := viper.GetString("service.state_file")
+	log.Debugf("config: state_file=%s", statePath)
+	state, err := loadState(statePath)
+	if err != nil {
+		log.Warnf("could not read state file, all records will be verified: %v", err)
+	}
+	log.Debugf("config: workers=%d provider_timeout=%s",
+		viper.GetInt("service.workers"), viper.GetString("service.provider_timeout"))
+
+	shutdownTimeout, _ := time.ParseDuration(viper.GetString("service.shutdown_timeout"))
+
+	ctx, cancel := shutdownContext()
+	defer cancel()
+	stopMQTT := startMQTT(viper.GetBool("service.run_once"))
+	defer stopMQTT()
 	if viper.GetBool("service.run_once") {
-		RunSync(dnsProviders)
+		ignoreReload(ctx)
+		RunSync(ctx, dnsProviders, state)
 	} else {
 		interval, err := time.ParseDuration(viper.GetString("service.sync_interval"))
 		if err != nil {
@@ -200,8 +291,62 @@ func run(cmd *cobra.Command, args []string) {
 				viper.GetString("service.sync_interval"), err)
 			os.Exit(1)
 		}
-		RunService(dnsProviders, interval)
+		reloader := newConfigReloader(dnsProviders)
+		// the listeners and watcher stop with ctx, they are waited on so
+		// none of them outlive the service
+		var running sync.WaitGroup
+		start := func(run func()) {
+			running.Add(1)
+			go func() {
+				defer running.Done()
+				run()
+			}()
+		}
+		start(func() { watchReload(ctx, reloader, viper.GetBool("service.watch_config")) })
+		if listen := viper.GetString("http.listen"); listen != "" {
+			start(func() { runAPIServer(ctx, listen, reloader, state) })
+		}
+		if socket := viper.GetString("http.socket"); socket != "" {
+			start(func() { runControlSocket(ctx, socket, reloader, state) })
+		}
+		RunService(ctx, reloader, state, interval, shutdownTimeout)
+		running.Wait()
+	}
+}
+
+// shutdownContext returns a context that is canceled on SIGINT or SIGTERM,
+// a second signal kills the process as usual
+func shutdownContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-signals:
+			log.Infof("service: received %s signal", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(signals)
+	}()
+	return ctx, cancel
+}
+
+// openLogFile points the log output at the configured log file, the
+// returned file should be closed by the caller when not nil
+func openLogFile() *os.File {
+	logFilePath := getLogFilePath(viper.GetString("log_file"))
+	log.Debugf("config: log_file=%s", logFilePath)
+	if strings.ToLower(logFilePath) == "stdout" || logFilePath == "" || logFilePath == "-" {
+		log.SetOutput(os.Stdout)
+		return nil
+	}
+	logFile, err := os.OpenFile(logFilePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		log.Fatalf("error opening log file=%v", err)
 	}
+	log.SetOutput(logFile)
+	return logFile
 }
 
 func getLogFilePath(defaultPath string) (logPath string) {
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/gesquive/dyngo/dns"
+	"github.com/gesquive/dyngo/ipcheck"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	syncCycles = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dyngo",
+		Name:      "sync_cycles_total",
+		Help:      "Sync cycles by result, one of success, failure or skipped.",
+	}, []string{"result"})
+
+	providerSyncs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dyngo",
+		Name:      "provider_syncs_total",
+		Help:      "Provider record syncs by action, one of created, updated, unchanged, synced or failed.",
+	}, []string{"provider", "record_type", "action"})
+
+	providerSyncDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "dyngo",
+		Name:      "provider_sync_duration_seconds",
+		Help:      "Time taken by provider api calls to sync a record.",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 10),
+	}, []string{"provider", "record_type"})
+
+	ipChecks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dyngo",
+		Name:      "ip_checks_total",
+		Help:      "Public address checks by source and result, one of success or failure.",
+	}, []string{"source", "family", "result"})
+
+	ipCheckDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "dyngo",
+		Name:      "ip_check_duration_seconds",
+		Help:      "Time taken by public address checks.",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 10),
+	}, []string{"source", "family"})
+
+	addressChanged = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "dyngo",
+		Name:      "address_change_timestamp_seconds",
+		Help:      "Unix time the detected public address last changed.",
+	}, []string{"record_type"})
+)
+
+// observeSyncCycle counts a finished sync cycle
+func observeSyncCycle(failed bool) {
+	status.CycleDone(failed)
+	if failed {
+		syncCycles.WithLabelValues("failure").Inc()
+	} else {
+		syncCycles.WithLabelValues("success").Inc()
+	}
+}
+
+// observeProviderSync counts a provider sync and its api latency
+func observeProviderSync(provider dns.Provider, recordType string, duration time.Duration,
+	result dns.Result, err error) {
+	status.SyncAttempted(provider, recordType, result, err)
+	publishRecord(status.Record(provider, recordType))
+	name := string(provider.GetName())
+	providerSyncDuration.WithLabelValues(name, recordType).Observe(duration.Seconds())
+	action := string(result.Action)
+	if err != nil {
+		action = "failed"
+	}
+	providerSyncs.WithLabelValues(name, recordType, action).Inc()
+}
+
+// observeIPCheck counts an address check and its latency
+func observeIPCheck(source ipcheck.Source, family ipcheck.Family, duration time.Duration, err error) {
+	label := sourceLabel(source)
+	ipCheckDuration.WithLabelValues(label, family.String()).Observe(duration.Seconds())
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	ipChecks.WithLabelValues(label, family.String(), result).Inc()
+}
+
+// sourceLabel returns the scheme, host and path of the source url, so
+// tokens in its userinfo or query never end up on /metrics
+func sourceLabel(source ipcheck.Source) string {
+	sourceURL, err := url.Parse(source.String())
+	if err != nil || sourceURL.Scheme == "" {
+		return "unknown"
+	}
+	return sourceURL.Scheme + "://" + sourceURL.Host + sourceURL.Path
+}
+
+// observeAddress records the time the detected address of recordType changed
+func observeAddress(recordType string, address string) {
+	if status.SetAddress(recordType, address) {
+		addressChanged.WithLabelValues(recordType).SetToCurrentTime()
+	}
+	publishAddress(recordType, address)
+}
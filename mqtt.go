@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+
+	"github.com/gesquive/dyngo/mqtt"
+	"github.com/spf13/viper"
+)
+
+// publisher publishes the addresses, record status and events of the
+// running service to mqtt, nil when no broker is configured
+var publisher *mqtt.Publisher
+
+// getPublisher returns a publisher for the mqtt config, or nil when no
+// broker is set
+func getPublisher() (*mqtt.Publisher, error) {
+	if viper.GetString("mqtt.broker") == "" {
+		return nil, nil
+	}
+	var config mqtt.Config
+	if err := viper.UnmarshalKey("mqtt", &config); err != nil {
+		return nil, err
+	}
+	return mqtt.NewPublisher(config)
+}
+
+// configureMQTT sets up the publisher, changes to it need a restart
+func configureMQTT() error {
+	p, err := getPublisher()
+	if err != nil {
+		return err
+	}
+	publisher = p
+	return nil
+}
+
+// startMQTT connects the publisher, once when runOnce is set and otherwise
+// in the background until it works. The returned func disconnects it
+func startMQTT(runOnce bool) (stop func()) {
+	if publisher == nil {
+		return func() {}
+	}
+	if runOnce {
+		if err := publisher.Connect(); err != nil {
+			log.Errorf("mqtt: could not connect err=%s", err)
+		}
+		return publisher.Close
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		publisher.Run(ctx)
+		close(done)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// publishAddress publishes the detected address of recordType
+func publishAddress(recordType string, address string) {
+	if publisher != nil {
+		publisher.PublishAddress(recordType, address)
+	}
+}
+
+// publishRecord publishes the status of the recordType record of provider
+func publishRecord(record providerStatus) {
+	if publisher != nil {
+		publisher.PublishRecord(record.Provider, record.Record, record.RecordType, record)
+	}
+}
@@ -0,0 +1,170 @@
+package mqtt
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// message is a publish the broker received
+type message struct {
+	topic    string
+	payload  string
+	retained bool
+	qos      byte
+}
+
+// session is what a client sent when it connected
+type session struct {
+	clientID  string
+	username  string
+	password  string
+	willTopic string
+	will      string
+}
+
+// broker is a minimal mqtt 3.1.1 broker that records what it is sent
+type broker struct {
+	listener net.Listener
+	messages chan message
+	sessions chan session
+	conns    []net.Conn
+	mutex    sync.Mutex
+}
+
+// newBroker starts a broker, tlsConfig makes it accept tls connections
+func newBroker(t *testing.T, tlsConfig *tls.Config) *broker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+	b := &broker{listener: listener, messages: make(chan message, 100), sessions: make(chan session, 10)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			b.mutex.Lock()
+			b.conns = append(b.conns, conn)
+			b.mutex.Unlock()
+			go b.handle(conn)
+		}
+	}()
+	return b
+}
+
+func (b *broker) address() string {
+	return b.listener.Addr().String()
+}
+
+// drop closes every client connection, like a broker restart
+func (b *broker) drop() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for _, conn := range b.conns {
+		conn.Close()
+	}
+	b.conns = nil
+}
+
+func (b *broker) close() {
+	b.listener.Close()
+	b.drop()
+}
+
+func (b *broker) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		header, err := reader.ReadByte()
+		if err != nil {
+			return
+		}
+		length, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return
+		}
+		body := make([]byte, length)
+		if _, err = io.ReadFull(reader, body); err != nil {
+			return
+		}
+
+		switch header >> 4 {
+		case 1: // CONNECT
+			b.sessions <- parseConnect(body)
+			conn.Write([]byte{0x20, 2, 0, 0})
+		case 3: // PUBLISH
+			msg := message{retained: header&1 == 1, qos: (header >> 1) & 3}
+			msg.topic, body = readString(body)
+			if msg.qos > 0 {
+				conn.Write([]byte{0x40, 2, body[0], body[1]})
+				body = body[2:]
+			}
+			msg.payload = string(body)
+			b.messages <- msg
+		case 12: // PINGREQ
+			conn.Write([]byte{0xd0, 0})
+		case 14: // DISCONNECT
+			return
+		}
+	}
+}
+
+func parseConnect(body []byte) session {
+	_, body = readString(body) // protocol name
+	flags := body[1]
+	body = body[4:] // level, flags and keep alive
+	s := session{}
+	s.clientID, body = readString(body)
+	if flags&0x04 != 0 {
+		s.willTopic, body = readString(body)
+		s.will, body = readString(body)
+	}
+	if flags&0x80 != 0 {
+		s.username, body = readString(body)
+	}
+	if flags&0x40 != 0 {
+		s.password, _ = readString(body)
+	}
+	return s
+}
+
+func readString(body []byte) (string, []byte) {
+	length := int(binary.BigEndian.Uint16(body))
+	return string(body[2 : 2+length]), body[2+length:]
+}
+
+// next returns the next message published to topic, skipping others
+func (b *broker) next(t *testing.T, topic string) message {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg := <-b.messages:
+			if msg.topic == topic {
+				return msg
+			}
+		case <-timeout:
+			t.Fatalf("nothing published to %s", topic)
+			return message{}
+		}
+	}
+}
+
+func (b *broker) session(t *testing.T) session {
+	select {
+	case s := <-b.sessions:
+		return s
+	case <-time.After(5 * time.Second):
+		t.Fatal("no client connected")
+		return session{}
+	}
+}
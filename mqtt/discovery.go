@@ -0,0 +1,45 @@
+package mqtt
+
+import (
+	"encoding/json"
+)
+
+// entity is a retained state topic, and how it shows up in Home Assistant
+type entity struct {
+	stateTopic string
+	objectID   string
+	name       string
+	// json is set when the state is a json object with a value field
+	json bool
+}
+
+// publishDiscovery publishes the Home Assistant discovery config of e as a
+// sensor, when discovery is on
+func (p *Publisher) publishDiscovery(e entity) {
+	if !p.config.Discovery {
+		return
+	}
+	config := map[string]interface{}{
+		"name":               e.name,
+		"unique_id":          p.nodeID + "_" + e.objectID,
+		"state_topic":        e.stateTopic,
+		"availability_topic": p.topic("status"),
+		"icon":               "mdi:ip-network",
+		"device": map[string]interface{}{
+			"identifiers": []string{p.nodeID},
+			"name":        p.config.ClientID,
+			"model":       "dyngo",
+		},
+	}
+	if e.json {
+		config["value_template"] = "{{ value_json.value }}"
+		config["json_attributes_topic"] = e.stateTopic
+	}
+	payload, err := json.Marshal(config)
+	if err != nil {
+		log.Errorf("mqtt: could not encode discovery config err=%s", err)
+		return
+	}
+	topic := p.config.DiscoveryPrefix + "/sensor/" + p.nodeID + "/" + e.objectID + "/config"
+	p.publish(topic, true, payload)
+}
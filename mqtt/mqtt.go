@@ -0,0 +1,328 @@
+package mqtt
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/gesquive/dyngo/notify"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var log = logrus.New()
+
+const publisherName = "mqtt"
+
+// Status payloads of the availability topic
+const (
+	online  = "online"
+	offline = "offline"
+)
+
+// Config is the mqtt publisher config
+type Config struct {
+	Broker             string        `mapstructure:"broker"`
+	ClientID           string        `mapstructure:"client_id"`
+	Username           string        `mapstructure:"username"`
+	Password           string        `mapstructure:"password"`
+	TopicPrefix        string        `mapstructure:"topic_prefix"`
+	QoS                byte          `mapstructure:"qos"`
+	Timeout            time.Duration `mapstructure:"timeout"`
+	CAFile             string        `mapstructure:"ca_file"`
+	CertFile           string        `mapstructure:"cert_file"`
+	KeyFile            string        `mapstructure:"key_file"`
+	InsecureSkipVerify bool          `mapstructure:"insecure_skip_verify"`
+	Events             []string      `mapstructure:"events"`
+	Discovery          bool          `mapstructure:"discovery"`
+	DiscoveryPrefix    string        `mapstructure:"discovery_prefix"`
+}
+
+// Publisher keeps retained topics with the current addresses and the
+// status of each record, and publishes events as they happen. Retained
+// topics are published again after a reconnect
+type Publisher struct {
+	name          notify.Name
+	config        Config
+	events        []notify.EventType
+	client        paho.Client
+	nodeID        string
+	retryInterval time.Duration
+
+	// retained holds the last payload of each retained topic, and topics
+	// their discovery config in the order they were first published
+	retained map[string][]byte
+	topics   []entity
+	mutex    sync.Mutex
+}
+
+// NewPublisher is Publisher constructor, it does not connect
+func NewPublisher(config Config) (*Publisher, error) {
+	if config.Broker == "" {
+		return nil, errors.New("broker missing from mqtt config")
+	}
+	if config.ClientID == "" {
+		config.ClientID = "dyngo"
+	}
+	if config.TopicPrefix == "" {
+		config.TopicPrefix = "dyngo"
+	}
+	config.TopicPrefix = strings.TrimRight(config.TopicPrefix, "/")
+	if config.DiscoveryPrefix == "" {
+		config.DiscoveryPrefix = "homeassistant"
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.QoS > 2 {
+		return nil, errors.New("qos must be 0, 1 or 2 in mqtt config")
+	}
+	events, err := notify.ParseEvents(config.Events)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid mqtt events")
+	}
+	tlsConfig, err := newTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Publisher{
+		name:          publisherName,
+		config:        config,
+		events:        events,
+		nodeID:        topicID(config.ClientID),
+		retryInterval: 5 * time.Second,
+		retained:      map[string][]byte{},
+	}
+	options := paho.NewClientOptions().
+		AddBroker(config.Broker).
+		SetClientID(config.ClientID).
+		SetUsername(config.Username).
+		SetPassword(config.Password).
+		SetTLSConfig(tlsConfig).
+		SetConnectTimeout(config.Timeout).
+		SetAutoReconnect(true).
+		SetWill(p.topic("status"), offline, config.QoS, true).
+		SetOnConnectHandler(p.connected).
+		SetConnectionLostHandler(func(client paho.Client, err error) {
+			log.Warnf("mqtt: lost connection to %s err=%s", config.Broker, err)
+		})
+	p.client = paho.NewClient(options)
+	return p, nil
+}
+
+// newTLSConfig returns the tls settings for ssl:// and tls:// brokers
+func newTLSConfig(config Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify}
+	if config.CAFile != "" {
+		pem, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read mqtt ca_file")
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in mqtt ca_file")
+		}
+	}
+	if config.CertFile != "" || config.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not load mqtt cert_file and key_file")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// GetName returns name identifier
+func (p *Publisher) GetName() notify.Name {
+	return p.name
+}
+
+// Events returns the event types to publish
+func (p *Publisher) Events() []notify.EventType {
+	return p.events
+}
+
+// Connect makes one attempt to connect to the broker
+func (p *Publisher) Connect() error {
+	token := p.client.Connect()
+	if !token.WaitTimeout(p.config.Timeout) {
+		return errors.Errorf("timed out connecting to %s", p.config.Broker)
+	}
+	return token.Error()
+}
+
+// Run connects to the broker, trying again until it works, and disconnects
+// when ctx is canceled. Once connected, the client reconnects by itself
+func (p *Publisher) Run(ctx context.Context) {
+	for {
+		err := p.Connect()
+		if err == nil {
+			break
+		}
+		log.Errorf("mqtt: could not connect to %s err=%s", p.config.Broker, err)
+		select {
+		case <-time.After(p.retryInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+	<-ctx.Done()
+	p.Close()
+}
+
+// Close marks the publisher offline and disconnects
+func (p *Publisher) Close() {
+	if !p.client.IsConnected() {
+		return
+	}
+	if p.client.IsConnectionOpen() {
+		p.wait(p.client.Publish(p.topic("status"), p.config.QoS, true, offline))
+	}
+	p.client.Disconnect(uint(p.config.Timeout / time.Millisecond))
+	log.Debugf("mqtt: disconnected from %s", p.config.Broker)
+}
+
+// connected publishes the availability, discovery and retained topics each
+// time the client connects
+func (p *Publisher) connected(client paho.Client) {
+	log.Infof("mqtt: connected to %s", p.config.Broker)
+	p.mutex.Lock()
+	topics := append([]entity{}, p.topics...)
+	payloads := map[string][]byte{}
+	for topic, payload := range p.retained {
+		payloads[topic] = payload
+	}
+	p.mutex.Unlock()
+
+	p.publish(p.topic("status"), true, []byte(online))
+	for _, topic := range topics {
+		p.publishDiscovery(topic)
+		p.publish(topic.stateTopic, true, payloads[topic.stateTopic])
+	}
+}
+
+// PublishAddress sets the retained topic of the recordType public address
+func (p *Publisher) PublishAddress(recordType string, address string) {
+	family := addressTopic(recordType)
+	p.setRetained(entity{
+		stateTopic: p.topic(family),
+		objectID:   family,
+		name:       "Public " + strings.Replace(family, "ip", "IP", 1) + " address",
+	}, []byte(address))
+}
+
+// PublishRecord sets the retained status topic of a provider record to the
+// json of status
+func (p *Publisher) PublishRecord(provider string, record string, recordType string,
+	status interface{}) {
+	payload, err := json.Marshal(status)
+	if err != nil {
+		log.Errorf("mqtt: could not encode record status err=%s", err)
+		return
+	}
+	p.setRetained(entity{
+		stateTopic: p.topic("records", provider, record, recordType),
+		objectID:   topicID(provider + "_" + record + "_" + recordType),
+		name:       record + " " + recordType + " (" + provider + ")",
+		json:       true,
+	}, payload)
+}
+
+// Notify publishes event to the events topic
+func (p *Publisher) Notify(ctx context.Context, event notify.Event) error {
+	if !p.client.IsConnectionOpen() {
+		return errors.New("not connected")
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	token := p.client.Publish(p.topic("events"), p.config.QoS, false, payload)
+	select {
+	case <-waitToken(token):
+		return token.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// setRetained publishes payload to the retained topic of e when it changed,
+// it is kept to publish again after a reconnect
+func (p *Publisher) setRetained(e entity, payload []byte) {
+	p.mutex.Lock()
+	previous, ok := p.retained[e.stateTopic]
+	if ok && string(previous) == string(payload) {
+		p.mutex.Unlock()
+		return
+	}
+	if !ok {
+		p.topics = append(p.topics, e)
+	}
+	p.retained[e.stateTopic] = payload
+	p.mutex.Unlock()
+
+	if !p.client.IsConnectionOpen() {
+		return
+	}
+	if !ok {
+		p.publishDiscovery(e)
+	}
+	p.publish(e.stateTopic, true, payload)
+}
+
+// publish sends payload without waiting for the broker, errors are logged
+func (p *Publisher) publish(topic string, retained bool, payload []byte) {
+	token := p.client.Publish(topic, p.config.QoS, retained, payload)
+	go func() {
+		if p.wait(token) && token.Error() != nil {
+			log.Errorf("mqtt: could not publish %s err=%s", topic, token.Error())
+		}
+	}()
+}
+
+// wait waits for token up to the timeout, and returns false if it timed out
+func (p *Publisher) wait(token paho.Token) bool {
+	return token.WaitTimeout(p.config.Timeout)
+}
+
+func waitToken(token paho.Token) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		token.Wait()
+		close(done)
+	}()
+	return done
+}
+
+// topic joins parts to the topic prefix
+func (p *Publisher) topic(parts ...string) string {
+	return p.config.TopicPrefix + "/" + strings.Join(parts, "/")
+}
+
+func addressTopic(recordType string) string {
+	if recordType == "AAAA" {
+		return "ipv6"
+	}
+	return "ipv4"
+}
+
+// IntializeLogging sets the logger to use in this library
+func IntializeLogging(logger *logrus.Logger) {
+	log = logger
+}
+
+var notTopicID = regexp.MustCompile("[^a-zA-Z0-9_-]+")
+
+// topicID returns name with only the characters allowed in discovery ids
+func topicID(name string) string {
+	return strings.Trim(notTopicID.ReplaceAllString(name, "_"), "_")
+}
@@ -0,0 +1,161 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gesquive/dyngo/notify"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPublisher(t *testing.T, config Config) *Publisher {
+	p, err := NewPublisher(config)
+	assert.NoError(t, err)
+	p.retryInterval = 50 * time.Millisecond
+	return p
+}
+
+func TestPublisherRetained(t *testing.T) {
+	b := newBroker(t, nil)
+	defer b.close()
+	p := newTestPublisher(t, Config{Broker: "tcp://" + b.address(), QoS: 1,
+		Username: "user", Password: "secret"})
+
+	// published before connecting, sent once connected
+	p.PublishAddress("A", "192.0.2.1")
+	assert.NoError(t, p.Connect())
+	s := b.session(t)
+	assert.Equal(t, session{clientID: "dyngo", username: "user", password: "secret",
+		willTopic: "dyngo/status", will: offline}, s)
+	assert.Equal(t, message{topic: "dyngo/status", payload: online, retained: true, qos: 1},
+		b.next(t, "dyngo/status"))
+	assert.Equal(t, message{topic: "dyngo/ipv4", payload: "192.0.2.1", retained: true, qos: 1},
+		b.next(t, "dyngo/ipv4"))
+
+	p.PublishAddress("AAAA", "2001:db8::1")
+	assert.Equal(t, "2001:db8::1", b.next(t, "dyngo/ipv6").payload)
+
+	p.PublishRecord("cloudflare", "home.domain.com", "A", map[string]string{"value": "192.0.2.1"})
+	msg := b.next(t, "dyngo/records/cloudflare/home.domain.com/A")
+	assert.True(t, msg.retained)
+	assert.JSONEq(t, `{"value": "192.0.2.1"}`, msg.payload)
+
+	// unchanged payloads are not sent again
+	p.PublishAddress("A", "192.0.2.1")
+	p.PublishAddress("A", "192.0.2.2")
+	assert.Equal(t, "192.0.2.2", b.next(t, "dyngo/ipv4").payload)
+
+	p.Close()
+	assert.Equal(t, offline, b.next(t, "dyngo/status").payload)
+}
+
+func TestPublisherReconnect(t *testing.T) {
+	b := newBroker(t, nil)
+	defer b.close()
+	p := newTestPublisher(t, Config{Broker: "tcp://" + b.address(), TopicPrefix: "home/dyngo/"})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx)
+		close(done)
+	}()
+
+	b.session(t)
+	p.PublishAddress("A", "192.0.2.1")
+	assert.Equal(t, "192.0.2.1", b.next(t, "home/dyngo/ipv4").payload)
+
+	b.drop()
+	b.session(t)
+	assert.Equal(t, online, b.next(t, "home/dyngo/status").payload)
+	assert.Equal(t, "192.0.2.1", b.next(t, "home/dyngo/ipv4").payload)
+
+	cancel()
+	<-done
+}
+
+func TestPublisherNotify(t *testing.T) {
+	b := newBroker(t, nil)
+	defer b.close()
+	p := newTestPublisher(t, Config{Broker: "tcp://" + b.address(), Events: []string{"record_updated"}})
+	assert.Equal(t, []notify.EventType{notify.RecordUpdated}, p.Events())
+
+	event := notify.Event{Type: notify.RecordUpdated, Provider: "cloudflare", Record: "home.domain.com",
+		RecordType: "A", OldValue: "192.0.2.1", NewValue: "192.0.2.2"}
+	assert.Error(t, p.Notify(context.Background(), event))
+
+	assert.NoError(t, p.Connect())
+	defer p.Close()
+	assert.NoError(t, p.Notify(context.Background(), event))
+	msg := b.next(t, "dyngo/events")
+	assert.False(t, msg.retained)
+	var published notify.Event
+	assert.NoError(t, json.Unmarshal([]byte(msg.payload), &published))
+	assert.Equal(t, event, published)
+}
+
+func TestPublisherDiscovery(t *testing.T) {
+	b := newBroker(t, nil)
+	defer b.close()
+	p := newTestPublisher(t, Config{Broker: "tcp://" + b.address(), ClientID: "dyngo home",
+		Discovery: true})
+	assert.NoError(t, p.Connect())
+	defer p.Close()
+
+	p.PublishRecord("cloudflare", "home.domain.com", "A", map[string]string{"value": "192.0.2.1"})
+	msg := b.next(t, "homeassistant/sensor/dyngo_home/cloudflare_home_domain_com_A/config")
+	assert.True(t, msg.retained)
+	var config map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(msg.payload), &config))
+	assert.Equal(t, "dyngo_home_cloudflare_home_domain_com_A", config["unique_id"])
+	assert.Equal(t, "dyngo/records/cloudflare/home.domain.com/A", config["state_topic"])
+	assert.Equal(t, "dyngo/status", config["availability_topic"])
+	assert.Equal(t, "{{ value_json.value }}", config["value_template"])
+	b.next(t, "dyngo/records/cloudflare/home.domain.com/A")
+
+	p.PublishAddress("A", "192.0.2.1")
+	msg = b.next(t, "homeassistant/sensor/dyngo_home/ipv4/config")
+	assert.NoError(t, json.Unmarshal([]byte(msg.payload), &config))
+	assert.Equal(t, "Public IPv4 address", config["name"])
+}
+
+func TestPublisherTLS(t *testing.T) {
+	server := httptest.NewTLSServer(nil)
+	defer server.Close()
+	b := newBroker(t, server.TLS)
+	defer b.close()
+
+	dir, err := ioutil.TempDir("", "dyngo")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	assert.NoError(t, ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{
+		Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+
+	p := newTestPublisher(t, Config{Broker: "ssl://" + b.address(), CAFile: caFile})
+	assert.NoError(t, p.Connect())
+	b.session(t)
+	p.Close()
+
+	p = newTestPublisher(t, Config{Broker: "ssl://" + b.address(), Timeout: time.Second})
+	assert.Error(t, p.Connect())
+}
+
+func TestPublisherConfig(t *testing.T) {
+	_, err := NewPublisher(Config{})
+	assert.Error(t, err)
+	_, err = NewPublisher(Config{Broker: "tcp://localhost:1883", QoS: 3})
+	assert.Error(t, err)
+	_, err = NewPublisher(Config{Broker: "tcp://localhost:1883", Events: []string{"ip_lost"}})
+	assert.Error(t, err)
+	_, err = NewPublisher(Config{Broker: "tcp://localhost:1883", CAFile: "/nonexistent/ca.pem"})
+	assert.Error(t, err)
+	_, err = NewPublisher(Config{Broker: "tcp://localhost:1883", CertFile: "/nonexistent/cert.pem"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gesquive/dyngo/dns"
+	"github.com/gesquive/dyngo/notify"
+	"github.com/spf13/viper"
+)
+
+// notifications sends the events of the running service
+var notifications = notify.NewDispatcher()
+
+// notificationsConfig is the notifier setup in the config
+type notificationsConfig struct {
+	subscriptions  []notify.Subscription
+	remindInterval time.Duration
+	timeout        time.Duration
+}
+
+// configureNotifications sets up the notifiers in the config
+func configureNotifications() error {
+	config, err := readNotifications()
+	if err != nil {
+		return err
+	}
+	config.apply()
+	return nil
+}
+
+// readNotifications returns the notifier setup in the config, without
+// using it
+func readNotifications() (notificationsConfig, error) {
+	config := notificationsConfig{}
+	var err error
+	if config.subscriptions, err = getNotifiers(); err != nil {
+		return config, err
+	}
+	if config.remindInterval, err = time.ParseDuration(viper.GetString("notifications.remind_interval")); err != nil {
+		return config, err
+	}
+	if config.timeout, err = time.ParseDuration(viper.GetString("notifications.timeout")); err != nil {
+		return config, err
+	}
+	if publisher != nil {
+		config.subscriptions = append(config.subscriptions,
+			notify.Subscription{Notifier: publisher, Events: publisher.Events()})
+	}
+	return config, nil
+}
+
+// apply makes config the running notifier setup
+func (config notificationsConfig) apply() {
+	notifications.Configure(config.subscriptions, config.remindInterval, config.timeout)
+}
+
+// dispatch sends event to the notifiers and hooks
+func dispatch(event notify.Event) {
+	event.Time = time.Now()
+	notifications.Dispatch(event)
+	hooks.Dispatch(event)
+}
+
+// recovered sends sync_recovered to the notifiers and hooks that were sent
+// a failure of the record
+func recovered(provider string, record string, recordType string) {
+	notifications.Recovered(provider, record, recordType)
+	hooks.Recovered(provider, record, recordType)
+}
+
+// notifyAddress sends ip_changed when address differs from the last one
+// detected, or on the first check the last one synced. Nothing is sent when
+// there is no previous address, it is only recorded by observeAddress
+func notifyAddress(state *syncState, recordType string, address string) {
+	recovered("", "", recordType)
+	previous := status.Address(recordType)
+	if previous == "" {
+		previous = state.LastAddress(recordType)
+	}
+	if previous == "" || previous == address {
+		return
+	}
+	dispatch(notify.Event{
+		Type:       notify.IPChanged,
+		RecordType: recordType,
+		OldValue:   previous,
+		NewValue:   address,
+	})
+}
+
+// notifyLookupFailed sends sync_failed when the public address could not
+// be detected
+func notifyLookupFailed(recordType string, err error) {
+	dispatch(notify.Event{
+		Type:       notify.SyncFailed,
+		RecordType: recordType,
+		Error:      err.Error(),
+	})
+}
+
+// notifyResult sends record_updated or record_created when a provider
+// changed its record
+func notifyResult(result dns.Result) {
+	recovered(string(result.Provider), result.Record, result.RecordType)
+	event := notify.Event{
+		Provider:   string(result.Provider),
+		Record:     result.Record,
+		RecordType: result.RecordType,
+		OldValue:   result.OldValue,
+		NewValue:   result.NewValue,
+	}
+	switch result.Action {
+	case dns.Updated:
+		event.Type = notify.RecordUpdated
+	case dns.Created:
+		event.Type = notify.RecordCreated
+	default:
+		return
+	}
+	dispatch(event)
+}
+
+// notifyFailed sends sync_failed for a provider sync that will not be
+// retried
+func notifyFailed(result dns.Result, err error) {
+	dispatch(notify.Event{
+		Type:       notify.SyncFailed,
+		Provider:   string(result.Provider),
+		Record:     result.Record,
+		RecordType: result.RecordType,
+		OldValue:   result.OldValue,
+		NewValue:   result.NewValue,
+		Error:      err.Error(),
+	})
+}
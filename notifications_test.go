@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gesquive/dyngo/notify"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeNotifier struct {
+	events []notify.Event
+	mutex  sync.Mutex
+}
+
+func (f *fakeNotifier) Notify(ctx context.Context, event notify.Event) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.events = append(f.events, event)
+	return nil
+}
+
+func (f *fakeNotifier) GetName() notify.Name {
+	return "fake"
+}
+
+func (f *fakeNotifier) Events() []notify.Event {
+	notifications.Wait()
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.events
+}
+
+func useFakeNotifier() *fakeNotifier {
+	notifier := &fakeNotifier{}
+	notifications = notify.NewDispatcher()
+	notifications.Configure([]notify.Subscription{
+		{Notifier: notifier, Events: notify.EventTypes},
+	}, 0, time.Second)
+	return notifier
+}
+
+func TestNotifySyncFailures(t *testing.T) {
+	notifier := useFakeNotifier()
+	defer func() { notifications = notify.NewDispatcher() }()
+
+	state, _ := loadState("")
+	provider := &fakeProvider{record: "home.domain.com", fail: true, permanent: true,
+		updates: map[string]string{}}
+	for i := 0; i < 3; i++ {
+		syncRecords(context.Background(), dnsProvidersList{provider}, state, "A", "192.0.2.1", false)
+	}
+	events := notifier.Events()
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, notify.SyncFailed, events[0].Type)
+		assert.Equal(t, "home.domain.com", events[0].Record)
+		assert.Equal(t, "fake failure", events[0].Error)
+	}
+
+	provider.fail = false
+	syncRecords(context.Background(), dnsProvidersList{provider}, state, "A", "192.0.2.1", false)
+	events = notifier.Events()
+	if assert.Len(t, events, 3) {
+		assert.ElementsMatch(t, []notify.EventType{notify.SyncRecovered, notify.RecordUpdated},
+			[]notify.EventType{events[1].Type, events[2].Type})
+	}
+}
+
+func TestNotifyAddress(t *testing.T) {
+	notifier := useFakeNotifier()
+	defer func() { notifications = notify.NewDispatcher() }()
+	status = newServiceStatus()
+	defer func() { status = newServiceStatus() }()
+
+	// the first address is compared to the last one synced
+	state, _ := loadState("")
+	state.Set(&fakeProvider{record: "home.domain.com"}, "A", "192.0.2.1")
+	notifyAddress(state, "A", "192.0.2.1")
+	observeAddress("A", "192.0.2.1")
+	assert.Empty(t, notifier.Events())
+
+	notifyAddress(state, "A", "192.0.2.2")
+	observeAddress("A", "192.0.2.2")
+	events := notifier.Events()
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, notify.IPChanged, events[0].Type)
+		assert.Equal(t, "192.0.2.1", events[0].OldValue)
+		assert.Equal(t, "192.0.2.2", events[0].NewValue)
+	}
+}
+
+func TestNotifyFirstAddress(t *testing.T) {
+	notifier := useFakeNotifier()
+	defer func() { notifications = notify.NewDispatcher() }()
+	status = newServiceStatus()
+	defer func() { status = newServiceStatus() }()
+
+	// without a state file there is nothing to compare the first address to
+	state, _ := loadState("")
+	notifyAddress(state, "A", "192.0.2.1")
+	observeAddress("A", "192.0.2.1")
+	assert.Empty(t, notifier.Events())
+
+	notifyAddress(state, "A", "192.0.2.2")
+	observeAddress("A", "192.0.2.2")
+	events := notifier.Events()
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "192.0.2.1", events[0].OldValue)
+		assert.Equal(t, "192.0.2.2", events[0].NewValue)
+	}
+}
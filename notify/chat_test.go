@@ -0,0 +1,181 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type stubRequest struct {
+	method string
+	path   string
+	header http.Header
+	body   []byte
+}
+
+func (r stubRequest) json(t *testing.T) map[string]interface{} {
+	var payload map[string]interface{}
+	assert.NoError(t, json.Unmarshal(r.body, &payload), string(r.body))
+	return payload
+}
+
+// newStubServer records each request and answers with status
+func newStubServer(status int) (*httptest.Server, chan stubRequest) {
+	requests := make(chan stubRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- stubRequest{method: r.Method, path: r.URL.Path, header: r.Header, body: body}
+		w.WriteHeader(status)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	return server, requests
+}
+
+func notifyStub(t *testing.T, config Config, event Event) stubRequest {
+	server, requests := newStubServer(http.StatusOK)
+	defer server.Close()
+	for key, value := range config {
+		if value == "STUB" {
+			config[key] = server.URL
+		}
+	}
+	notifier, _, err := GetNotifier(config)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, notifier.Notify(context.Background(), event))
+	return <-requests
+}
+
+func TestSlackNotify(t *testing.T) {
+	req := notifyStub(t, Config{"type": "slack", "url": "STUB", "channel": "#ops"}, failedEvent)
+	assert.Equal(t, http.MethodPost, req.method)
+	payload := req.json(t)
+	assert.Equal(t, failedEvent.Message(), payload["text"])
+	assert.Equal(t, "#ops", payload["channel"])
+	blocks := payload["blocks"].([]interface{})
+	assert.Len(t, blocks, 3)
+	assert.Equal(t, "header", blocks[0].(map[string]interface{})["type"])
+	assert.Contains(t, string(req.body), "*Error:* invalid credentials")
+}
+
+func TestDiscordNotify(t *testing.T) {
+	req := notifyStub(t, Config{"type": "discord", "url": "STUB", "username": "dyngo"}, updatedEvent)
+	payload := req.json(t)
+	assert.Equal(t, "dyngo", payload["username"])
+	embed := payload["embeds"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, updatedEvent.Message(), embed["description"])
+	assert.Equal(t, float64(discordGreen), embed["color"])
+	assert.Equal(t, "2019-10-01T12:00:00Z", embed["timestamp"])
+	assert.Len(t, embed["fields"], 5)
+}
+
+func TestTelegramNotify(t *testing.T) {
+	req := notifyStub(t, Config{"type": "telegram", "token": "123:abc", "chat_id": -100123,
+		"endpoint": "STUB"}, failedEvent)
+	assert.Equal(t, "/bot123:abc/sendMessage", req.path)
+	payload := req.json(t)
+	assert.Equal(t, "-100123", payload["chat_id"])
+	assert.Equal(t, "HTML", payload["parse_mode"])
+	assert.Contains(t, payload["text"], "<b>dyngo: sync failed</b>\n")
+	assert.Contains(t, payload["text"], "<b>Error:</b> <code>invalid credentials</code>")
+}
+
+func TestTelegramRedactsToken(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	notifier, err := NewTelegramNotifier(Config{"token": "123:abc", "chat_id": "1", "endpoint": server.URL})
+	assert.NoError(t, err)
+	err = notifier.Notify(context.Background(), failedEvent)
+	if assert.Error(t, err) {
+		assert.NotContains(t, err.Error(), "123:abc")
+		assert.Contains(t, err.Error(), "/botREDACTED/sendMessage")
+	}
+}
+
+func TestMatrixNotify(t *testing.T) {
+	req := notifyStub(t, Config{"type": "matrix", "homeserver": "STUB", "access_token": "secret",
+		"room_id": "!room:domain.com"}, updatedEvent)
+	assert.Equal(t, http.MethodPut, req.method)
+	assert.True(t, strings.HasPrefix(req.path, "/_matrix/client/v3/rooms/!room:domain.com/send/m.room.message/dyngo-"))
+	assert.Equal(t, "Bearer secret", req.header.Get("Authorization"))
+	payload := req.json(t)
+	assert.Equal(t, "m.notice", payload["msgtype"])
+	assert.Contains(t, payload["body"], updatedEvent.Message())
+	assert.Contains(t, payload["formatted_body"], "<strong>New:</strong> <code>192.0.2.2</code>")
+}
+
+func TestNtfyNotify(t *testing.T) {
+	req := notifyStub(t, Config{"type": "ntfy", "topic": "dyngo", "token": "tk_secret",
+		"endpoint": "STUB"}, failedEvent)
+	assert.Equal(t, "/", req.path)
+	assert.Equal(t, "Bearer tk_secret", req.header.Get("Authorization"))
+	payload := req.json(t)
+	assert.Equal(t, "dyngo", payload["topic"])
+	assert.Equal(t, failedEvent.Message(), payload["message"])
+	assert.Equal(t, float64(4), payload["priority"])
+
+	req = notifyStub(t, Config{"type": "ntfy", "topic": "dyngo", "username": "dyngo",
+		"password": "secret", "endpoint": "STUB"}, updatedEvent)
+	user, pass, ok := (&http.Request{Header: req.header}).BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "dyngo:secret", user+":"+pass)
+	assert.Equal(t, float64(3), req.json(t)["priority"])
+}
+
+func TestGotifyNotify(t *testing.T) {
+	req := notifyStub(t, Config{"type": "gotify", "endpoint": "STUB", "token": "secret"}, failedEvent)
+	assert.Equal(t, "/message", req.path)
+	assert.Equal(t, "secret", req.header.Get("X-Gotify-Key"))
+	payload := req.json(t)
+	assert.Equal(t, failedEvent.Title(), payload["title"])
+	assert.Equal(t, float64(8), payload["priority"])
+
+	req = notifyStub(t, Config{"type": "gotify", "endpoint": "STUB", "token": "secret"}, updatedEvent)
+	assert.Equal(t, float64(5), req.json(t)["priority"])
+}
+
+func TestPushoverNotify(t *testing.T) {
+	req := notifyStub(t, Config{"type": "pushover", "token": "app", "user": "me", "sound": "siren",
+		"endpoint": "STUB"}, failedEvent)
+	form, err := url.ParseQuery(string(req.body))
+	assert.NoError(t, err)
+	assert.Equal(t, "app", form.Get("token"))
+	assert.Equal(t, "me", form.Get("user"))
+	assert.Equal(t, failedEvent.Message(), form.Get("message"))
+	assert.Equal(t, "1", form.Get("priority"))
+	assert.Equal(t, "siren", form.Get("sound"))
+	assert.Equal(t, "1569931200", form.Get("timestamp"))
+}
+
+func TestChatNotifyError(t *testing.T) {
+	server, _ := newStubServer(http.StatusUnauthorized)
+	defer server.Close()
+	notifier, err := NewGotifyNotifier(Config{"type": "gotify", "endpoint": server.URL, "token": "bad"})
+	assert.NoError(t, err)
+	assert.Error(t, notifier.Notify(context.Background(), failedEvent))
+}
+
+func TestChatConfig(t *testing.T) {
+	for _, config := range []Config{
+		{"type": "slack"},
+		{"type": "discord"},
+		{"type": "telegram", "token": "123:abc"},
+		{"type": "telegram", "chat_id": "1"},
+		{"type": "matrix", "homeserver": "https://matrix.org", "access_token": "secret"},
+		{"type": "ntfy"},
+		{"type": "gotify", "token": "secret"},
+		{"type": "pushover", "token": "app"},
+		{"type": "slack", "url": "http://localhost/", "webhook": "http://localhost/"},
+	} {
+		_, _, err := GetNotifier(config)
+		assert.Error(t, err, "%v", config)
+	}
+}
@@ -0,0 +1,91 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const discordName = "discord"
+
+// Discord embed colors
+const (
+	discordRed   = 0xe74c3c
+	discordGreen = 0x2ecc71
+	discordBlue  = 0x3498db
+)
+
+// discordConfig is the discord notifier config
+type discordConfig struct {
+	URL       string `mapstructure:"url"`
+	Username  string `mapstructure:"username"`
+	AvatarURL string `mapstructure:"avatar_url"`
+}
+
+// Discord notifier posts events to a discord webhook
+type Discord struct {
+	name      Name
+	url       string
+	username  string
+	avatarURL string
+	client    *http.Client
+}
+
+// NewDiscordNotifier is Discord constructor
+func NewDiscordNotifier(config Config) (*Discord, error) {
+	var c discordConfig
+	if err := decode(config, &c, true); err != nil {
+		return nil, err
+	}
+	if c.URL == "" {
+		return nil, errors.New("url missing from discord notifier")
+	}
+	return &Discord{
+		name:      discordName,
+		url:       c.URL,
+		username:  c.Username,
+		avatarURL: c.AvatarURL,
+		client:    &http.Client{},
+	}, nil
+}
+
+// GetName returns name identifier
+func (d *Discord) GetName() Name {
+	return d.name
+}
+
+// Notify posts event as an embed
+func (d *Discord) Notify(ctx context.Context, event Event) error {
+	fields := []map[string]interface{}{}
+	for _, f := range eventFields(event) {
+		fields = append(fields, map[string]interface{}{
+			"name": f.Name, "value": f.Value, "inline": f.Name != "Error",
+		})
+	}
+	color := discordBlue
+	switch event.Type {
+	case SyncFailed:
+		color = discordRed
+	case SyncRecovered, RecordCreated, RecordUpdated:
+		color = discordGreen
+	}
+
+	payload := map[string]interface{}{
+		"embeds": []map[string]interface{}{{
+			"title":       event.Title(),
+			"description": event.Message(),
+			"color":       color,
+			"timestamp":   event.Time.Format(time.RFC3339),
+			"fields":      fields,
+		}},
+	}
+	if d.username != "" {
+		payload["username"] = d.username
+	}
+	if d.avatarURL != "" {
+		payload["avatar_url"] = d.avatarURL
+	}
+	return postJSON(ctx, d.client, http.MethodPost, d.url, payload, nil)
+}
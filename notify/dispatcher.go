@@ -0,0 +1,180 @@
+package notify
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Subscription is a notifier and the event types it is sent. When Provider
+// and Record are set, only events about that record are sent
+type Subscription struct {
+	Notifier Notifier
+	Events   []EventType
+	Provider string
+	Record   string
+}
+
+// wants returns true if the subscription includes event
+func (s Subscription) wants(event Event) bool {
+	if s.Provider != "" && !strings.EqualFold(s.Provider, event.Provider) {
+		return false
+	}
+	if s.Record != "" && !strings.EqualFold(s.Record, event.Record) {
+		return false
+	}
+	for _, eventType := range s.Events {
+		if eventType == event.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// Dispatcher sends events to the configured notifiers. A failure is only
+// sent once until it recovers, or remindInterval passes
+type Dispatcher struct {
+	subscriptions  []Subscription
+	remindInterval time.Duration
+	timeout        time.Duration
+	// failures holds when each failing record was last sent
+	failures map[string]time.Time
+	pending  sync.WaitGroup
+	mutex    sync.Mutex
+}
+
+// NewDispatcher returns a dispatcher without any notifiers
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{
+		failures: map[string]time.Time{},
+	}
+}
+
+// Configure replaces the notifiers, failures already sent are remembered
+// so a config reload does not repeat them. Replaced notifiers send the
+// events they held back. A remindInterval of zero never repeats a failure,
+// and timeout limits each notifier call
+func (d *Dispatcher) Configure(subscriptions []Subscription, remindInterval time.Duration,
+	timeout time.Duration) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for _, old := range d.subscriptions {
+		if flusher, ok := old.Notifier.(FlushNotifier); ok && !subscribed(subscriptions, flusher) {
+			d.flush(flusher)
+		}
+	}
+	d.subscriptions = subscriptions
+	d.remindInterval = remindInterval
+	d.timeout = timeout
+}
+
+// Dispatch sends event to every notifier that wants it, without waiting
+// for them. Repeats of a failure that was already sent are only sent to
+// notifiers that want repeats
+func (d *Dispatcher) Dispatch(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if event.Type == SyncFailed {
+		key := event.key()
+		if sent, ok := d.failures[key]; ok &&
+			(d.remindInterval <= 0 || event.Time.Sub(sent) < d.remindInterval) {
+			log.Debugf("notify: already sent failure of %s, holding back the repeat", key)
+			event.Repeat = true
+			d.send(event)
+			return
+		}
+		d.failures[key] = event.Time
+	}
+	d.send(event)
+}
+
+// Recovered sends a sync_recovered event if a failure of the record was
+// sent, use empty provider and record for the public address lookup
+func (d *Dispatcher) Recovered(provider string, record string, recordType string) {
+	event := Event{
+		Type:       SyncRecovered,
+		Time:       time.Now(),
+		Provider:   provider,
+		Record:     record,
+		RecordType: recordType,
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	key := event.key()
+	if _, ok := d.failures[key]; !ok {
+		return
+	}
+	delete(d.failures, key)
+	d.send(event)
+}
+
+// send starts a call to each notifier that wants event, d.mutex must be held
+func (d *Dispatcher) send(event Event) {
+	for _, subscription := range d.subscriptions {
+		if !subscription.wants(event) {
+			continue
+		}
+		if event.Repeat {
+			if repeater, ok := subscription.Notifier.(RepeatNotifier); !ok || !repeater.WantsRepeats() {
+				continue
+			}
+		}
+		d.pending.Add(1)
+		go func(notifier Notifier, timeout time.Duration) {
+			defer d.pending.Done()
+			ctx := context.Background()
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+			if err := notifier.Notify(ctx, event); err != nil {
+				log.Errorf("notify: %s could not send %s err=%s", notifier.GetName(), event.Type, err)
+				return
+			}
+			log.Debugf("notify: %s sent %s", notifier.GetName(), event.Type)
+		}(subscription.Notifier, d.timeout)
+	}
+}
+
+// flush starts sending the events flusher held back, d.mutex must be held
+func (d *Dispatcher) flush(flusher FlushNotifier) {
+	d.pending.Add(1)
+	go func() {
+		defer d.pending.Done()
+		flusher.Flush()
+	}()
+}
+
+// subscribed returns true if notifier is in subscriptions
+func subscribed(subscriptions []Subscription, notifier Notifier) bool {
+	for _, subscription := range subscriptions {
+		if subscription.Notifier == notifier {
+			return true
+		}
+	}
+	return false
+}
+
+// Flush waits for the events sent so far, then has the notifiers send the
+// events they held back and waits for those too
+func (d *Dispatcher) Flush() {
+	d.pending.Wait()
+	d.mutex.Lock()
+	for _, subscription := range d.subscriptions {
+		if flusher, ok := subscription.Notifier.(FlushNotifier); ok {
+			d.flush(flusher)
+		}
+	}
+	d.mutex.Unlock()
+	d.pending.Wait()
+}
+
+// Wait blocks until every event sent so far has been delivered or failed
+func (d *Dispatcher) Wait() {
+	d.pending.Wait()
+}
@@ -0,0 +1,198 @@
+package notify
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeNotifier struct {
+	events []Event
+	mutex  sync.Mutex
+}
+
+func (f *fakeNotifier) Notify(ctx context.Context, event Event) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.events = append(f.events, event)
+	return nil
+}
+
+func (f *fakeNotifier) GetName() Name {
+	return "fake"
+}
+
+func (f *fakeNotifier) types() []EventType {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	types := []EventType{}
+	for _, event := range f.events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func failure(record string) Event {
+	return Event{Type: SyncFailed, Provider: "fake", Record: record, RecordType: "A", Error: "fake failure"}
+}
+
+func TestDispatchFilter(t *testing.T) {
+	all := &fakeNotifier{}
+	failures := &fakeNotifier{}
+	d := NewDispatcher()
+	d.Configure([]Subscription{
+		{Notifier: all, Events: EventTypes},
+		{Notifier: failures, Events: []EventType{SyncFailed}},
+	}, 0, time.Second)
+
+	d.Dispatch(Event{Type: IPChanged, RecordType: "A", NewValue: "192.0.2.1"})
+	d.Dispatch(failure("home.domain.com"))
+	d.Wait()
+
+	assert.ElementsMatch(t, []EventType{IPChanged, SyncFailed}, all.types())
+	assert.Equal(t, []EventType{SyncFailed}, failures.types())
+}
+
+func TestDispatchDeduplicatesFailures(t *testing.T) {
+	notifier := &fakeNotifier{}
+	d := NewDispatcher()
+	d.Configure([]Subscription{{Notifier: notifier, Events: EventTypes}}, 0, time.Second)
+
+	for i := 0; i < 3; i++ {
+		d.Dispatch(failure("home.domain.com"))
+	}
+	d.Dispatch(failure("work.domain.com"))
+	d.Wait()
+	assert.Equal(t, []EventType{SyncFailed, SyncFailed}, notifier.types())
+
+	// a success sends one recovery, and lets the next failure through
+	d.Recovered("fake", "home.domain.com", "A")
+	d.Recovered("fake", "home.domain.com", "A")
+	d.Wait()
+	d.Dispatch(failure("home.domain.com"))
+	d.Wait()
+	assert.Equal(t, []EventType{SyncFailed, SyncFailed, SyncRecovered, SyncFailed}, notifier.types())
+}
+
+func TestDispatchRemindInterval(t *testing.T) {
+	notifier := &fakeNotifier{}
+	d := NewDispatcher()
+	d.Configure([]Subscription{{Notifier: notifier, Events: EventTypes}}, time.Hour, time.Second)
+
+	event := failure("home.domain.com")
+	event.Time = time.Now()
+	d.Dispatch(event)
+	event.Time = event.Time.Add(30 * time.Minute)
+	d.Dispatch(event)
+	event.Time = event.Time.Add(31 * time.Minute)
+	d.Dispatch(event)
+	d.Wait()
+	assert.Equal(t, []EventType{SyncFailed, SyncFailed}, notifier.types())
+}
+
+func TestRecoveredWithoutFailure(t *testing.T) {
+	notifier := &fakeNotifier{}
+	d := NewDispatcher()
+	d.Configure([]Subscription{{Notifier: notifier, Events: EventTypes}}, 0, time.Second)
+
+	d.Recovered("fake", "home.domain.com", "A")
+	d.Wait()
+	assert.Empty(t, notifier.types())
+}
+
+func TestGetNotifier(t *testing.T) {
+	notifier, events, err := GetNotifier(Config{"type": "Webhook", "url": "http://localhost/hook",
+		"events": []interface{}{"sync_failed", "IP_CHANGED"}})
+	assert.NoError(t, err)
+	assert.Equal(t, Name("webhook"), notifier.GetName())
+	assert.Equal(t, []EventType{SyncFailed, IPChanged}, events)
+
+	_, events, err = GetNotifier(Config{"type": "webhook", "url": "http://localhost/hook"})
+	assert.NoError(t, err)
+	assert.Equal(t, EventTypes, events)
+
+	_, _, err = GetNotifier(Config{"url": "http://localhost/hook"})
+	assert.Error(t, err)
+	_, _, err = GetNotifier(Config{"type": "carrier-pigeon"})
+	assert.Error(t, err)
+	_, _, err = GetNotifier(Config{"type": "webhook", "url": "http://localhost/hook",
+		"events": []interface{}{"ip_changd"}})
+	assert.Error(t, err)
+	_, _, err = GetNotifier(Config{"type": "webhook", "url": "http://localhost/hook", "methd": "PUT"})
+	assert.Error(t, err)
+}
+
+func TestEventMessage(t *testing.T) {
+	assert.Equal(t, "Public IPv6 address changed from 2001:db8::1 to 2001:db8::2",
+		Event{Type: IPChanged, RecordType: "AAAA", OldValue: "2001:db8::1", NewValue: "2001:db8::2"}.Message())
+	assert.Equal(t, "Could not sync A record home.domain.com on fake: fake failure",
+		failure("home.domain.com").Message())
+	assert.Equal(t, "Could not detect the public IPv4 address: no sources",
+		Event{Type: SyncFailed, RecordType: "A", Error: "no sources"}.Message())
+}
+
+type fakeRepeatNotifier struct {
+	fakeNotifier
+}
+
+func (f *fakeRepeatNotifier) WantsRepeats() bool {
+	return true
+}
+
+func TestDispatchRepeats(t *testing.T) {
+	notifier := &fakeNotifier{}
+	repeater := &fakeRepeatNotifier{}
+	d := NewDispatcher()
+	d.Configure([]Subscription{
+		{Notifier: notifier, Events: EventTypes},
+		{Notifier: repeater, Events: EventTypes},
+	}, 0, time.Second)
+
+	for i := 0; i < 3; i++ {
+		d.Dispatch(failure("home.domain.com"))
+	}
+	d.Wait()
+	assert.Len(t, notifier.events, 1)
+	repeats := 0
+	for _, event := range repeater.events {
+		if event.Repeat {
+			repeats++
+		}
+	}
+	assert.Len(t, repeater.events, 3)
+	assert.Equal(t, 2, repeats)
+}
+
+type fakeFlushNotifier struct {
+	fakeNotifier
+	flushed int
+}
+
+func (f *fakeFlushNotifier) Flush() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.flushed++
+}
+
+func TestDispatchFlush(t *testing.T) {
+	kept := &fakeFlushNotifier{}
+	replaced := &fakeFlushNotifier{}
+	d := NewDispatcher()
+	d.Configure([]Subscription{
+		{Notifier: kept, Events: EventTypes},
+		{Notifier: replaced, Events: EventTypes},
+	}, 0, time.Second)
+
+	// a notifier that is no longer configured sends what it held back
+	d.Configure([]Subscription{{Notifier: kept, Events: EventTypes}}, 0, time.Second)
+	d.Wait()
+	assert.Equal(t, 0, kept.flushed)
+	assert.Equal(t, 1, replaced.flushed)
+
+	d.Flush()
+	assert.Equal(t, 1, kept.flushed)
+	assert.Equal(t, 1, replaced.flushed)
+}
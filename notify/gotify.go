@@ -0,0 +1,71 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const gotifyName = "gotify"
+
+// gotifyConfig is the gotify notifier config
+type gotifyConfig struct {
+	Endpoint string `mapstructure:"endpoint"`
+	Token    string `mapstructure:"token"`
+	Priority int    `mapstructure:"priority"`
+}
+
+// Gotify notifier sends events to a gotify server
+type Gotify struct {
+	name     Name
+	endpoint string
+	token    string
+	priority int
+	client   *http.Client
+}
+
+// NewGotifyNotifier is Gotify constructor
+func NewGotifyNotifier(config Config) (*Gotify, error) {
+	var c gotifyConfig
+	if err := decode(config, &c, true); err != nil {
+		return nil, err
+	}
+	if c.Endpoint == "" {
+		return nil, errors.New("endpoint missing from gotify notifier")
+	}
+	if c.Token == "" {
+		return nil, errors.New("token missing from gotify notifier")
+	}
+	if c.Priority == 0 {
+		c.Priority = 5
+	}
+	return &Gotify{
+		name:     gotifyName,
+		endpoint: strings.TrimRight(c.Endpoint, "/"),
+		token:    c.Token,
+		priority: c.Priority,
+		client:   &http.Client{},
+	}, nil
+}
+
+// GetName returns name identifier
+func (g *Gotify) GetName() Name {
+	return g.name
+}
+
+// Notify sends event as a message, failures are sent with a high priority
+func (g *Gotify) Notify(ctx context.Context, event Event) error {
+	priority := g.priority
+	if event.Type == SyncFailed && priority < 8 {
+		priority = 8
+	}
+	payload := map[string]interface{}{
+		"title":    event.Title(),
+		"message":  event.Message(),
+		"priority": priority,
+	}
+	return postJSON(ctx, g.client, http.MethodPost, g.endpoint+"/message", payload,
+		map[string]string{"X-Gotify-Key": g.token})
+}
@@ -0,0 +1,103 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// send makes the request and fails on any non 2xx response
+func send(ctx context.Context, client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("unexpected response status=%d body=%q",
+			resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// templateFuncs are available in every notifier template
+var templateFuncs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+}
+
+// newTemplate parses text as a template of an Event
+func newTemplate(name string, text string) (*template.Template, error) {
+	return template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+}
+
+// render executes tmpl with event
+func render(tmpl *template.Template, event Event) (string, error) {
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, event); err != nil {
+		return "", fmt.Errorf("could not render %s template: %v", tmpl.Name(), err)
+	}
+	return out.String(), nil
+}
+
+// newJSONRequest returns a request with payload as its json body
+func newJSONRequest(method string, url string, payload interface{}) (*http.Request, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// postJSON sends payload as json to url with method
+func postJSON(ctx context.Context, client *http.Client, method string, url string,
+	payload interface{}, headers map[string]string) error {
+	req, err := newJSONRequest(method, url, payload)
+	if err != nil {
+		return err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	return send(ctx, client, req)
+}
+
+// field is a labeled detail of an event
+type field struct {
+	Name  string
+	Value string
+}
+
+// eventFields returns the details of event that are set, for notifiers
+// that format them separately from the message
+func eventFields(event Event) []field {
+	fields := []field{}
+	add := func(name string, value string) {
+		if value != "" {
+			fields = append(fields, field{name, value})
+		}
+	}
+	add("Provider", event.Provider)
+	add("Record", event.Record)
+	add("Type", event.RecordType)
+	add("Old", event.OldValue)
+	add("New", event.NewValue)
+	add("Error", event.Error)
+	return fields
+}
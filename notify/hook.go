@@ -0,0 +1,152 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+)
+
+const hookName = "hook"
+
+// HookConfig is a command to run on an event
+type HookConfig struct {
+	Command string        `mapstructure:"command"`
+	Args    []string      `mapstructure:"args"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// ParseHookConfigs reads a list of hooks from the raw config value, each
+// entry can be a command line or a map of options
+func ParseHookConfigs(raw interface{}) ([]HookConfig, error) {
+	var entries []interface{}
+	switch value := raw.(type) {
+	case nil:
+	case string:
+		entries = append(entries, value)
+	case []string:
+		for _, entry := range value {
+			entries = append(entries, entry)
+		}
+	case []interface{}:
+		entries = value
+	default:
+		return nil, errors.Errorf("hooks must be a list, not %T", raw)
+	}
+
+	configs := make([]HookConfig, 0, len(entries))
+	for _, entry := range entries {
+		config := HookConfig{}
+		if line, ok := entry.(string); ok {
+			fields := strings.Fields(line)
+			if len(fields) > 0 {
+				config.Command = fields[0]
+				config.Args = fields[1:]
+			}
+		} else {
+			decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+				DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+				ErrorUnused:      true,
+				WeaklyTypedInput: true,
+				Result:           &config,
+			})
+			if err != nil {
+				return nil, err
+			}
+			if err = decoder.Decode(entry); err != nil {
+				return nil, errors.Wrap(err, "could not parse hook")
+			}
+		}
+		if config.Command == "" {
+			return nil, errors.New("hook is missing a command")
+		}
+		configs = append(configs, config)
+	}
+	return configs, nil
+}
+
+// Hook runs a command with the event in its environment and on stdin
+type Hook struct {
+	name    Name
+	command string
+	args    []string
+	timeout time.Duration
+}
+
+// NewHook is Hook constructor, timeout is used when the config has none
+func NewHook(config HookConfig, timeout time.Duration) *Hook {
+	if config.Timeout > 0 {
+		timeout = config.Timeout
+	}
+	return &Hook{
+		name:    hookName,
+		command: config.Command,
+		args:    config.Args,
+		timeout: timeout,
+	}
+}
+
+// GetName returns name identifier
+func (h *Hook) GetName() Name {
+	return h.name
+}
+
+// Notify runs the command, its output is logged
+func (h *Hook) Notify(ctx context.Context, event Event) error {
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+	input, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, h.command, h.args...)
+	cmd.Env = append(os.Environ(), hookEnv(event)...)
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	log.Debugf("hook: running cmd %v for %s", cmd.Args, event.Type)
+	err = cmd.Run()
+	if stdout.Len() > 0 {
+		log.Infof("hook: '%s' stdout: %s", h.command, strings.TrimSpace(stdout.String()))
+	}
+	if err != nil {
+		log.Errorf("hook: '%s' returned with errors", h.command)
+		if stderr.Len() > 0 {
+			log.Errorf("stderr: %s", strings.TrimSpace(stderr.String()))
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			return errors.Errorf("timed out after %s", h.timeout)
+		}
+		return err
+	}
+	if stderr.Len() > 0 {
+		log.Warnf("hook: '%s' stderr: %s", h.command, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// hookEnv returns the event as environment variables
+func hookEnv(event Event) []string {
+	return []string{
+		"DYNGO_EVENT=" + string(event.Type),
+		"DYNGO_TIME=" + event.Time.Format(time.RFC3339),
+		"DYNGO_PROVIDER=" + event.Provider,
+		"DYNGO_RECORD=" + event.Record,
+		"DYNGO_RECORD_TYPE=" + event.RecordType,
+		"DYNGO_OLD_ADDRESS=" + event.OldValue,
+		"DYNGO_NEW_ADDRESS=" + event.NewValue,
+		"DYNGO_ERROR=" + event.Error,
+		"DYNGO_MESSAGE=" + event.Message(),
+	}
+}
@@ -0,0 +1,109 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeHookScript(t *testing.T, dir string, script string) string {
+	path := filepath.Join(dir, "hook.sh")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script), 0700))
+	return path
+}
+
+func TestHookNotify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dyngo")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out")
+	path := writeHookScript(t, dir, `echo "$1 $DYNGO_EVENT $DYNGO_PROVIDER $DYNGO_RECORD $DYNGO_RECORD_TYPE $DYNGO_OLD_ADDRESS $DYNGO_NEW_ADDRESS" > "$2"
+cat >> "$2"
+`)
+
+	hook := NewHook(HookConfig{Command: path, Args: []string{"wg0", out}}, time.Second)
+	assert.NoError(t, hook.Notify(context.Background(), updatedEvent))
+
+	data, err := ioutil.ReadFile(out)
+	assert.NoError(t, err)
+	lines := strings.SplitN(string(data), "\n", 2)
+	assert.Equal(t, "wg0 record_updated cloudflare home.domain.com A 192.0.2.1 192.0.2.2", lines[0])
+	var event Event
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &event))
+	assert.Equal(t, updatedEvent, event)
+}
+
+func TestHookFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dyngo")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := writeHookScript(t, dir, "echo oops >&2\nexit 3\n")
+	assert.Error(t, NewHook(HookConfig{Command: path}, time.Second).Notify(context.Background(), updatedEvent))
+
+	assert.Error(t, NewHook(HookConfig{Command: filepath.Join(dir, "missing")}, time.Second).
+		Notify(context.Background(), updatedEvent))
+}
+
+func TestHookTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dyngo")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := writeHookScript(t, dir, "exec sleep 5\n")
+	hook := NewHook(HookConfig{Command: path, Timeout: 50 * time.Millisecond}, time.Minute)
+	start := time.Now()
+	err = hook.Notify(context.Background(), updatedEvent)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out after 50ms")
+	assert.True(t, time.Since(start) < 2*time.Second)
+}
+
+func TestParseHookConfigs(t *testing.T) {
+	configs, err := ParseHookConfigs([]interface{}{
+		"/usr/local/bin/restart-wg.sh wg0",
+		map[interface{}]interface{}{"command": "/usr/local/bin/flush.sh", "args": []interface{}{"--all"},
+			"timeout": "5s"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []HookConfig{
+		{Command: "/usr/local/bin/restart-wg.sh", Args: []string{"wg0"}},
+		{Command: "/usr/local/bin/flush.sh", Args: []string{"--all"}, Timeout: 5 * time.Second},
+	}, configs)
+
+	configs, err = ParseHookConfigs("/usr/local/bin/flush.sh")
+	assert.NoError(t, err)
+	assert.Len(t, configs, 1)
+	configs, err = ParseHookConfigs(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, configs)
+
+	_, err = ParseHookConfigs([]interface{}{map[interface{}]interface{}{"args": "--all"}})
+	assert.Error(t, err)
+	_, err = ParseHookConfigs([]interface{}{map[interface{}]interface{}{"command": "x", "cmd": "y"}})
+	assert.Error(t, err)
+	_, err = ParseHookConfigs(42)
+	assert.Error(t, err)
+}
+
+func TestSubscriptionRecordFilter(t *testing.T) {
+	notifier := &fakeNotifier{}
+	d := NewDispatcher()
+	d.Configure([]Subscription{{Notifier: notifier, Events: EventTypes,
+		Provider: "Cloudflare", Record: "home.domain.com"}}, 0, time.Second)
+
+	d.Dispatch(updatedEvent)
+	other := updatedEvent
+	other.Record = "work.domain.com"
+	d.Dispatch(other)
+	d.Dispatch(Event{Type: IPChanged, RecordType: "A", NewValue: "192.0.2.2"})
+	d.Wait()
+	assert.Equal(t, []EventType{RecordUpdated}, notifier.types())
+}
@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const matrixName = "matrix"
+
+// matrixConfig is the matrix notifier config
+type matrixConfig struct {
+	Homeserver  string `mapstructure:"homeserver"`
+	AccessToken string `mapstructure:"access_token"`
+	RoomID      string `mapstructure:"room_id"`
+}
+
+// Matrix notifier sends events to a room with the client-server API
+type Matrix struct {
+	name        Name
+	homeserver  string
+	accessToken string
+	roomID      string
+	txnCount    uint64
+	client      *http.Client
+}
+
+// NewMatrixNotifier is Matrix constructor
+func NewMatrixNotifier(config Config) (*Matrix, error) {
+	var c matrixConfig
+	if err := decode(config, &c, true); err != nil {
+		return nil, err
+	}
+	if c.Homeserver == "" {
+		return nil, errors.New("homeserver missing from matrix notifier")
+	}
+	if c.AccessToken == "" {
+		return nil, errors.New("access_token missing from matrix notifier")
+	}
+	if c.RoomID == "" {
+		return nil, errors.New("room_id missing from matrix notifier")
+	}
+	return &Matrix{
+		name:        matrixName,
+		homeserver:  strings.TrimRight(c.Homeserver, "/"),
+		accessToken: c.AccessToken,
+		roomID:      c.RoomID,
+		client:      &http.Client{},
+	}, nil
+}
+
+// GetName returns name identifier
+func (m *Matrix) GetName() Name {
+	return m.name
+}
+
+// Notify sends event as a notice to the room
+func (m *Matrix) Notify(ctx context.Context, event Event) error {
+	plain := event.Title() + "\n" + event.Message()
+	formatted := fmt.Sprintf("<strong>%s</strong><br>%s",
+		html.EscapeString(event.Title()), html.EscapeString(event.Message()))
+	for _, f := range eventFields(event) {
+		plain += fmt.Sprintf("\n%s: %s", f.Name, f.Value)
+		formatted += fmt.Sprintf("<br><strong>%s:</strong> <code>%s</code>", f.Name, html.EscapeString(f.Value))
+	}
+	payload := map[string]interface{}{
+		"msgtype":        "m.notice",
+		"body":           plain,
+		"format":         "org.matrix.custom.html",
+		"formatted_body": formatted,
+	}
+
+	// the transaction id lets the homeserver drop a resent event
+	txnID := fmt.Sprintf("dyngo-%d-%d", time.Now().UnixNano(), atomic.AddUint64(&m.txnCount, 1))
+	sendURL := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+		m.homeserver, url.PathEscape(m.roomID), txnID)
+	return postJSON(ctx, m.client, http.MethodPut, sendURL, payload,
+		map[string]string{"Authorization": "Bearer " + m.accessToken})
+}
@@ -0,0 +1,237 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var log = logrus.New()
+
+// Name is the notifier type name
+type Name string
+
+// EventType is what happened
+type EventType string
+
+// Event types
+const (
+	IPChanged     EventType = "ip_changed"
+	RecordUpdated EventType = "record_updated"
+	RecordCreated EventType = "record_created"
+	SyncFailed    EventType = "sync_failed"
+	SyncRecovered EventType = "sync_recovered"
+)
+
+// EventTypes lists every event type
+var EventTypes = []EventType{IPChanged, RecordUpdated, RecordCreated, SyncFailed, SyncRecovered}
+
+// Event is something worth telling someone about. Provider and Record are
+// empty for events about the public address instead of a single record
+type Event struct {
+	Type       EventType `json:"type"`
+	Time       time.Time `json:"time"`
+	Provider   string    `json:"provider,omitempty"`
+	Record     string    `json:"record,omitempty"`
+	RecordType string    `json:"record_type,omitempty"`
+	OldValue   string    `json:"old_value,omitempty"`
+	NewValue   string    `json:"new_value,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	// Repeat is set on failures that were already sent
+	Repeat bool `json:"repeat,omitempty"`
+}
+
+// MarshalJSON includes the message with the event fields
+func (e Event) MarshalJSON() ([]byte, error) {
+	type event Event
+	return json.Marshal(struct {
+		event
+		Message string `json:"message"`
+	}{event(e), e.Message()})
+}
+
+// Message returns a short human readable description of the event
+func (e Event) Message() string {
+	switch e.Type {
+	case IPChanged:
+		if e.OldValue == "" {
+			return fmt.Sprintf("Public %s address is %s", e.family(), e.NewValue)
+		}
+		return fmt.Sprintf("Public %s address changed from %s to %s", e.family(), e.OldValue, e.NewValue)
+	case RecordUpdated:
+		return fmt.Sprintf("Updated %s record %s on %s from %s to %s",
+			e.RecordType, e.Record, e.Provider, e.OldValue, e.NewValue)
+	case RecordCreated:
+		return fmt.Sprintf("Created %s record %s on %s with %s",
+			e.RecordType, e.Record, e.Provider, e.NewValue)
+	case SyncFailed:
+		if e.Provider == "" {
+			return fmt.Sprintf("Could not detect the public %s address: %s", e.family(), e.Error)
+		}
+		return fmt.Sprintf("Could not sync %s record %s on %s: %s",
+			e.RecordType, e.Record, e.Provider, e.Error)
+	case SyncRecovered:
+		if e.Provider == "" {
+			return fmt.Sprintf("Public %s address detection is working again", e.family())
+		}
+		return fmt.Sprintf("%s record %s on %s is syncing again", e.RecordType, e.Record, e.Provider)
+	}
+	return string(e.Type)
+}
+
+// Title returns a one line summary of the event
+func (e Event) Title() string {
+	switch e.Type {
+	case IPChanged:
+		return "dyngo: public address changed"
+	case RecordUpdated, RecordCreated:
+		return "dyngo: " + e.Record + " updated"
+	case SyncFailed:
+		return "dyngo: sync failed"
+	case SyncRecovered:
+		return "dyngo: sync recovered"
+	}
+	return "dyngo: " + string(e.Type)
+}
+
+// Failure returns true for events about something going wrong
+func (e Event) Failure() bool {
+	return e.Type == SyncFailed
+}
+
+func (e Event) family() string {
+	if e.RecordType == "AAAA" {
+		return "IPv6"
+	}
+	return "IPv4"
+}
+
+// key identifies the thing the event is about, for de-duplication
+func (e Event) key() string {
+	return e.Provider + "/" + e.Record + "/" + e.RecordType
+}
+
+// Notifier generic interface
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+	GetName() Name
+}
+
+// RepeatNotifier is a notifier that can also be sent the failures the
+// dispatcher holds back as repeats, ie. to collect them into a digest
+type RepeatNotifier interface {
+	Notifier
+	WantsRepeats() bool
+}
+
+// FlushNotifier is a notifier that holds events back, Flush sends them
+// right away
+type FlushNotifier interface {
+	Notifier
+	Flush()
+}
+
+// Config is the generic notifier config format
+type Config map[string]interface{}
+
+// commonConfig holds the options every notifier has
+type commonConfig struct {
+	Type   string   `mapstructure:"type"`
+	Events []string `mapstructure:"events"`
+}
+
+// GetNotifier returns a notifier, and the event types it wants, from a
+// given config
+func GetNotifier(config Config) (notifier Notifier, events []EventType, err error) {
+	var common commonConfig
+	if err = decode(config, &common, false); err != nil {
+		return
+	}
+	if common.Type == "" {
+		err = errors.New("config missing notifier type")
+		return
+	}
+	events, err = ParseEvents(common.Events)
+	if err != nil {
+		return
+	}
+
+	cleanName := strings.ToLower(strings.TrimSpace(common.Type))
+	switch cleanName {
+	case webhookName:
+		notifier, err = NewWebhookNotifier(config)
+	case smtpName:
+		notifier, err = NewSMTPNotifier(config)
+	case slackName:
+		notifier, err = NewSlackNotifier(config)
+	case discordName:
+		notifier, err = NewDiscordNotifier(config)
+	case telegramName:
+		notifier, err = NewTelegramNotifier(config)
+	case matrixName:
+		notifier, err = NewMatrixNotifier(config)
+	case ntfyName:
+		notifier, err = NewNtfyNotifier(config)
+	case gotifyName:
+		notifier, err = NewGotifyNotifier(config)
+	case pushoverName:
+		notifier, err = NewPushoverNotifier(config)
+	default:
+		err = errors.Errorf("notifier type '%s' not recognized", cleanName)
+	}
+	return
+}
+
+// ParseEvents checks the given event names, no names means every event
+func ParseEvents(names []string) ([]EventType, error) {
+	if len(names) == 0 {
+		return EventTypes, nil
+	}
+	events := []EventType{}
+	for _, name := range names {
+		found := false
+		for _, event := range EventTypes {
+			if strings.EqualFold(name, string(event)) {
+				events = append(events, event)
+				found = true
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("event '%s' not recognized", name)
+		}
+	}
+	return events, nil
+}
+
+// decode reads config into a notifier config struct, the common options
+// are ignored when checking for unknown options
+func decode(config Config, result interface{}, strict bool) error {
+	options := Config{}
+	for key, value := range config {
+		if strict && (key == "type" || key == "events") {
+			continue
+		}
+		options[key] = value
+	}
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		ErrorUnused:      strict,
+		WeaklyTypedInput: true,
+		Result:           result,
+	})
+	if err != nil {
+		return err
+	}
+	return errors.Wrapf(decoder.Decode(options), "invalid %s notifier config", config["type"])
+}
+
+// IntializeLogging sets the logger to use in this library
+func IntializeLogging(logger *logrus.Logger) {
+	log = logger
+}
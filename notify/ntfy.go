@@ -0,0 +1,92 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const ntfyName = "ntfy"
+
+const ntfyEndpoint = "https://ntfy.sh"
+
+// ntfyConfig is the ntfy notifier config
+type ntfyConfig struct {
+	Topic    string `mapstructure:"topic"`
+	Token    string `mapstructure:"token"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	Endpoint string `mapstructure:"endpoint"`
+}
+
+// Ntfy notifier publishes events to an ntfy topic
+type Ntfy struct {
+	name     Name
+	topic    string
+	token    string
+	username string
+	password string
+	endpoint string
+	client   *http.Client
+}
+
+// NewNtfyNotifier is Ntfy constructor
+func NewNtfyNotifier(config Config) (*Ntfy, error) {
+	var c ntfyConfig
+	if err := decode(config, &c, true); err != nil {
+		return nil, err
+	}
+	if c.Topic == "" {
+		return nil, errors.New("topic missing from ntfy notifier")
+	}
+	if c.Endpoint == "" {
+		c.Endpoint = ntfyEndpoint
+	}
+	return &Ntfy{
+		name:     ntfyName,
+		topic:    c.Topic,
+		token:    c.Token,
+		username: c.Username,
+		password: c.Password,
+		endpoint: strings.TrimRight(c.Endpoint, "/"),
+		client:   &http.Client{},
+	}, nil
+}
+
+// GetName returns name identifier
+func (n *Ntfy) GetName() Name {
+	return n.name
+}
+
+// Notify publishes event, failures are sent with a high priority
+func (n *Ntfy) Notify(ctx context.Context, event Event) error {
+	priority := 3
+	tags := []string{"globe_with_meridians"}
+	switch event.Type {
+	case SyncFailed:
+		priority = 4
+		tags = []string{"warning"}
+	case SyncRecovered, RecordCreated, RecordUpdated:
+		tags = []string{"white_check_mark"}
+	}
+	payload := map[string]interface{}{
+		"topic":    n.topic,
+		"title":    event.Title(),
+		"message":  event.Message(),
+		"priority": priority,
+		"tags":     tags,
+	}
+
+	req, err := newJSONRequest(http.MethodPost, n.endpoint+"/", payload)
+	if err != nil {
+		return err
+	}
+	if n.token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	} else if n.username != "" {
+		req.SetBasicAuth(n.username, n.password)
+	}
+	return send(ctx, n.client, req)
+}
@@ -0,0 +1,92 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const pushoverName = "pushover"
+
+const pushoverEndpoint = "https://api.pushover.net/1/messages.json"
+
+// pushoverConfig is the pushover notifier config
+type pushoverConfig struct {
+	Token    string `mapstructure:"token"`
+	User     string `mapstructure:"user"`
+	Device   string `mapstructure:"device"`
+	Sound    string `mapstructure:"sound"`
+	Endpoint string `mapstructure:"endpoint"`
+}
+
+// Pushover notifier sends events with the pushover api
+type Pushover struct {
+	name     Name
+	token    string
+	user     string
+	device   string
+	sound    string
+	endpoint string
+	client   *http.Client
+}
+
+// NewPushoverNotifier is Pushover constructor
+func NewPushoverNotifier(config Config) (*Pushover, error) {
+	var c pushoverConfig
+	if err := decode(config, &c, true); err != nil {
+		return nil, err
+	}
+	if c.Token == "" {
+		return nil, errors.New("token missing from pushover notifier")
+	}
+	if c.User == "" {
+		return nil, errors.New("user missing from pushover notifier")
+	}
+	if c.Endpoint == "" {
+		c.Endpoint = pushoverEndpoint
+	}
+	return &Pushover{
+		name:     pushoverName,
+		token:    c.Token,
+		user:     c.User,
+		device:   c.Device,
+		sound:    c.Sound,
+		endpoint: c.Endpoint,
+		client:   &http.Client{},
+	}, nil
+}
+
+// GetName returns name identifier
+func (p *Pushover) GetName() Name {
+	return p.name
+}
+
+// Notify sends event as a message, failures are sent with a high priority
+func (p *Pushover) Notify(ctx context.Context, event Event) error {
+	form := url.Values{}
+	form.Set("token", p.token)
+	form.Set("user", p.user)
+	form.Set("title", event.Title())
+	form.Set("message", event.Message())
+	form.Set("timestamp", strconv.FormatInt(event.Time.Unix(), 10))
+	if event.Type == SyncFailed {
+		form.Set("priority", "1")
+	}
+	if p.device != "" {
+		form.Set("device", p.device)
+	}
+	if p.sound != "" {
+		form.Set("sound", p.sound)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return send(ctx, p.client, req)
+}
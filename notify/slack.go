@@ -0,0 +1,100 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const slackName = "slack"
+
+// slackConfig is the slack notifier config
+type slackConfig struct {
+	URL       string `mapstructure:"url"`
+	Channel   string `mapstructure:"channel"`
+	Username  string `mapstructure:"username"`
+	IconEmoji string `mapstructure:"icon_emoji"`
+}
+
+// Slack notifier posts events to a slack incoming webhook
+type Slack struct {
+	name      Name
+	url       string
+	channel   string
+	username  string
+	iconEmoji string
+	client    *http.Client
+}
+
+// NewSlackNotifier is Slack constructor
+func NewSlackNotifier(config Config) (*Slack, error) {
+	var c slackConfig
+	if err := decode(config, &c, true); err != nil {
+		return nil, err
+	}
+	if c.URL == "" {
+		return nil, errors.New("url missing from slack notifier")
+	}
+	return &Slack{
+		name:      slackName,
+		url:       c.URL,
+		channel:   c.Channel,
+		username:  c.Username,
+		iconEmoji: c.IconEmoji,
+		client:    &http.Client{},
+	}, nil
+}
+
+// GetName returns name identifier
+func (s *Slack) GetName() Name {
+	return s.name
+}
+
+// Notify posts event as a message with blocks
+func (s *Slack) Notify(ctx context.Context, event Event) error {
+	details := []string{}
+	for _, f := range eventFields(event) {
+		details = append(details, fmt.Sprintf("*%s:* %s", f.Name, slackEscape(f.Value)))
+	}
+	blocks := []map[string]interface{}{
+		{
+			"type": "header",
+			"text": map[string]interface{}{"type": "plain_text", "text": event.Title()},
+		},
+		{
+			"type": "section",
+			"text": map[string]interface{}{"type": "mrkdwn", "text": slackEscape(event.Message())},
+		},
+	}
+	if len(details) > 0 {
+		blocks = append(blocks, map[string]interface{}{
+			"type": "context",
+			"elements": []map[string]interface{}{
+				{"type": "mrkdwn", "text": strings.Join(details, "  |  ")},
+			},
+		})
+	}
+
+	payload := map[string]interface{}{
+		"text":   event.Message(),
+		"blocks": blocks,
+	}
+	if s.channel != "" {
+		payload["channel"] = s.channel
+	}
+	if s.username != "" {
+		payload["username"] = s.username
+	}
+	if s.iconEmoji != "" {
+		payload["icon_emoji"] = s.iconEmoji
+	}
+	return postJSON(ctx, s.client, http.MethodPost, s.url, payload, nil)
+}
+
+// slackEscape escapes the characters slack uses for markup
+func slackEscape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
@@ -0,0 +1,371 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const smtpName = "smtp"
+
+// SMTP security modes
+const (
+	smtpStartTLS = "starttls"
+	smtpTLS      = "tls"
+	smtpNone     = "none"
+)
+
+const defaultSMTPSubject = "{{.Title}}"
+
+const defaultSMTPBody = `{{.Message}}
+
+Event:    {{.Type}}
+Time:     {{.Time.Format "2006-01-02 15:04:05 MST"}}
+{{- if .Provider}}
+Provider: {{.Provider}}
+Record:   {{.Record}} ({{.RecordType}})
+{{- end}}
+{{- if .OldValue}}
+Old:      {{.OldValue}}
+{{- end}}
+{{- if .NewValue}}
+New:      {{.NewValue}}
+{{- end}}
+{{- if .Error}}
+Error:    {{.Error}}
+{{- end}}
+`
+
+// smtpConfig is the smtp notifier config
+type smtpConfig struct {
+	Host         string        `mapstructure:"host"`
+	Port         int           `mapstructure:"port"`
+	Security     string        `mapstructure:"security"`
+	Username     string        `mapstructure:"username"`
+	Password     string        `mapstructure:"password"`
+	From         string        `mapstructure:"from"`
+	To           []string      `mapstructure:"to"`
+	Subject      string        `mapstructure:"subject"`
+	Body         string        `mapstructure:"body"`
+	RateLimit    int           `mapstructure:"rate_limit"`
+	RateInterval time.Duration `mapstructure:"rate_interval"`
+	Digest       time.Duration `mapstructure:"digest"`
+}
+
+// digestEntry is a failure held back for the digest
+type digestEntry struct {
+	event Event
+	count int
+	first time.Time
+}
+
+// SMTP notifier emails events. At most rateLimit emails are sent each
+// rateInterval, and when digest is set repeated failures are collected
+// into one email sent every digest
+type SMTP struct {
+	name         Name
+	address      string
+	host         string
+	security     string
+	auth         smtp.Auth
+	from         string
+	to           []string
+	subject      *template.Template
+	body         *template.Template
+	rateLimit    int
+	rateInterval time.Duration
+	digest       time.Duration
+	tlsConfig    *tls.Config
+
+	sent        []time.Time
+	digested    map[string]*digestEntry
+	digestTimer *time.Timer
+	mutex       sync.Mutex
+}
+
+// NewSMTPNotifier is SMTP constructor
+func NewSMTPNotifier(config Config) (*SMTP, error) {
+	var c smtpConfig
+	if err := decode(config, &c, true); err != nil {
+		return nil, err
+	}
+	s := &SMTP{
+		name:         smtpName,
+		host:         c.Host,
+		security:     strings.ToLower(c.Security),
+		from:         c.From,
+		to:           c.To,
+		rateLimit:    c.RateLimit,
+		rateInterval: c.RateInterval,
+		digest:       c.Digest,
+		digested:     map[string]*digestEntry{},
+	}
+	if s.host == "" {
+		return nil, errors.New("host missing from smtp notifier")
+	}
+	if s.from == "" {
+		return nil, errors.New("from missing from smtp notifier")
+	}
+	if len(s.to) == 0 {
+		return nil, errors.New("to missing from smtp notifier")
+	}
+	if s.security == "" {
+		s.security = smtpStartTLS
+		if c.Port == 465 {
+			s.security = smtpTLS
+		}
+	}
+	switch s.security {
+	case smtpStartTLS:
+		if c.Port == 0 {
+			c.Port = 587
+		}
+	case smtpNone:
+		if c.Port == 0 {
+			c.Port = 25
+		}
+	case smtpTLS:
+		if c.Port == 0 {
+			c.Port = 465
+		}
+	default:
+		return nil, errors.Errorf("security '%s' not recognized in smtp notifier", s.security)
+	}
+	s.address = net.JoinHostPort(s.host, strconv.Itoa(c.Port))
+	if c.Username != "" {
+		s.auth = smtp.PlainAuth("", c.Username, c.Password, s.host)
+	}
+	if s.rateLimit > 0 && s.rateInterval <= 0 {
+		s.rateInterval = time.Hour
+	}
+	s.tlsConfig = &tls.Config{ServerName: s.host}
+
+	if c.Subject == "" {
+		c.Subject = defaultSMTPSubject
+	}
+	if c.Body == "" {
+		c.Body = defaultSMTPBody
+	}
+	var err error
+	if s.subject, err = newTemplate("subject", c.Subject); err != nil {
+		return nil, errors.Wrap(err, "invalid subject in smtp notifier")
+	}
+	if s.body, err = newTemplate("body", c.Body); err != nil {
+		return nil, errors.Wrap(err, "invalid body in smtp notifier")
+	}
+	return s, nil
+}
+
+// GetName returns name identifier
+func (s *SMTP) GetName() Name {
+	return s.name
+}
+
+// WantsRepeats returns true when repeated failures go into a digest
+func (s *SMTP) WantsRepeats() bool {
+	return s.digest > 0
+}
+
+// Notify emails event, repeats and events over the rate limit are added to
+// the digest instead
+func (s *SMTP) Notify(ctx context.Context, event Event) error {
+	if event.Repeat {
+		s.addToDigest(event)
+		return nil
+	}
+	if !s.allow(time.Now()) {
+		if s.digest > 0 {
+			log.Warnf("notify: smtp rate limit reached, adding %s to the digest", event.Type)
+			s.addToDigest(event)
+			return nil
+		}
+		return errors.Errorf("rate limit reached, dropped %s", event.Type)
+	}
+
+	subject, err := render(s.subject, event)
+	if err != nil {
+		return err
+	}
+	body, err := render(s.body, event)
+	if err != nil {
+		return err
+	}
+	return s.send(ctx, subject, body)
+}
+
+// allow returns true if another email can be sent at now, and counts it
+func (s *SMTP) allow(now time.Time) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.rateLimit <= 0 {
+		return true
+	}
+	recent := s.sent[:0]
+	for _, sent := range s.sent {
+		if now.Sub(sent) < s.rateInterval {
+			recent = append(recent, sent)
+		}
+	}
+	s.sent = recent
+	if len(s.sent) >= s.rateLimit {
+		return false
+	}
+	s.sent = append(s.sent, now)
+	return true
+}
+
+// addToDigest holds event back for the next digest, which is scheduled
+// when the digest was empty
+func (s *SMTP) addToDigest(event Event) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	key := string(event.Type) + ":" + event.key()
+	entry, ok := s.digested[key]
+	if !ok {
+		entry = &digestEntry{first: event.Time}
+		s.digested[key] = entry
+	}
+	entry.event = event
+	entry.count++
+	if s.digestTimer == nil {
+		s.digestTimer = time.AfterFunc(s.digest, s.sendDigest)
+	}
+}
+
+// Flush sends the digest now instead of waiting for it to be due
+func (s *SMTP) Flush() {
+	s.mutex.Lock()
+	if s.digestTimer != nil {
+		s.digestTimer.Stop()
+	}
+	s.mutex.Unlock()
+	s.sendDigest()
+}
+
+// sendDigest emails the events held back since the last digest
+func (s *SMTP) sendDigest() {
+	s.mutex.Lock()
+	entries := make([]*digestEntry, 0, len(s.digested))
+	for _, entry := range s.digested {
+		entries = append(entries, entry)
+	}
+	s.digested = map[string]*digestEntry{}
+	s.digestTimer = nil
+	s.mutex.Unlock()
+	if len(entries) == 0 {
+		return
+	}
+
+	subject, body := formatDigest(entries)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := s.send(ctx, subject, body); err != nil {
+		log.Errorf("notify: smtp could not send digest err=%s", err)
+	}
+}
+
+// formatDigest returns the subject and body of a digest email
+func formatDigest(entries []*digestEntry) (string, string) {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].first.Before(entries[j].first)
+	})
+	count := 0
+	var body bytes.Buffer
+	for _, entry := range entries {
+		count += entry.count
+		fmt.Fprintf(&body, "%s\n  %d times from %s to %s\n\n", entry.event.Message(), entry.count,
+			entry.first.Format("2006-01-02 15:04:05 MST"),
+			entry.event.Time.Format("2006-01-02 15:04:05 MST"))
+	}
+	return fmt.Sprintf("dyngo: digest of %d held back events", count), body.String()
+}
+
+// send delivers an email to every recipient
+func (s *SMTP) send(ctx context.Context, subject string, body string) error {
+	client, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if s.auth != nil {
+		if err = client.Auth(s.auth); err != nil {
+			return err
+		}
+	}
+	if err = client.Mail(s.from); err != nil {
+		return err
+	}
+	for _, to := range s.to {
+		if err = client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = writer.Write(s.message(subject, body)); err != nil {
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// dial connects to the server and secures the connection, the connection
+// is closed when ctx is done
+func (s *SMTP) dial(ctx context.Context) (*smtp.Client, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if s.security == smtpTLS {
+		conn = tls.Client(conn, s.tlsConfig)
+	}
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if s.security == smtpStartTLS {
+		if err = client.StartTLS(s.tlsConfig); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// message formats the email, with the subject encoded as needed
+func (s *SMTP) message(subject string, body string) []byte {
+	subject = strings.Join(strings.Fields(subject), " ")
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("\r\n")
+	body = strings.Replace(body, "\r\n", "\n", -1)
+	msg.WriteString(strings.Replace(body, "\n", "\r\n", -1))
+	return msg.Bytes()
+}
@@ -0,0 +1,317 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type smtpMessage struct {
+	auth   string
+	from   string
+	to     []string
+	data   string
+	secure bool
+}
+
+// smtpServer is a minimal smtp server that accepts every email
+type smtpServer struct {
+	listener  net.Listener
+	tlsConfig *tls.Config
+	messages  chan smtpMessage
+}
+
+// newSMTPServer starts an smtp server, tlsConfig enables STARTTLS or, when
+// implicit is set, tls on connect
+func newSMTPServer(t *testing.T, tlsConfig *tls.Config, implicit bool) *smtpServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	if implicit {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+	s := &smtpServer{listener: listener, messages: make(chan smtpMessage, 10)}
+	if !implicit {
+		s.tlsConfig = tlsConfig
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(conn, implicit)
+		}
+	}()
+	return s
+}
+
+func (s *smtpServer) handle(conn net.Conn, secure bool) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost ESMTP")
+	msg := smtpMessage{secure: secure}
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO", "HELO":
+			if s.tlsConfig != nil && !msg.secure {
+				text.PrintfLine("250-localhost")
+				text.PrintfLine("250-STARTTLS")
+			} else {
+				text.PrintfLine("250-localhost")
+			}
+			text.PrintfLine("250 AUTH PLAIN")
+		case "STARTTLS":
+			text.PrintfLine("220 ready")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if tlsConn.Handshake() != nil {
+				return
+			}
+			conn = tlsConn
+			text = textproto.NewConn(conn)
+			msg.secure = true
+		case "AUTH":
+			fields := strings.Fields(line)
+			auth, _ := base64.StdEncoding.DecodeString(fields[len(fields)-1])
+			msg.auth = string(auth)
+			text.PrintfLine("235 ok")
+		case "MAIL":
+			msg.from = strings.Trim(strings.SplitN(line, ":", 2)[1], "<>")
+			text.PrintfLine("250 ok")
+		case "RCPT":
+			msg.to = append(msg.to, strings.Trim(strings.SplitN(line, ":", 2)[1], "<>"))
+			text.PrintfLine("250 ok")
+		case "DATA":
+			text.PrintfLine("354 go ahead")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.data = string(data)
+			text.PrintfLine("250 ok")
+			s.messages <- msg
+		case "QUIT":
+			text.PrintfLine("221 bye")
+			return
+		default:
+			text.PrintfLine("250 ok")
+		}
+	}
+}
+
+func (s *smtpServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpServer) next(t *testing.T) smtpMessage {
+	select {
+	case msg := <-s.messages:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no email received")
+	}
+	return smtpMessage{}
+}
+
+// testTLS borrows the certificate of an httptest server, which is valid
+// for 127.0.0.1, and returns the server and client tls configs
+func testTLS() (*tls.Config, *x509.CertPool) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	return &tls.Config{Certificates: server.TLS.Certificates},
+		server.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+}
+
+func newTestSMTP(t *testing.T, server *smtpServer, config Config) *SMTP {
+	options := Config{"type": "smtp", "host": "127.0.0.1", "port": server.port(),
+		"from": "dyngo@domain.com", "to": []interface{}{"admin@domain.com", "ops@domain.com"}}
+	for key, value := range config {
+		options[key] = value
+	}
+	s, err := NewSMTPNotifier(options)
+	assert.NoError(t, err)
+	return s
+}
+
+var failedEvent = Event{
+	Type:       SyncFailed,
+	Time:       time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC),
+	Provider:   "cloudflare",
+	Record:     "home.domain.com",
+	RecordType: "A",
+	NewValue:   "192.0.2.2",
+	Error:      "invalid credentials",
+}
+
+func TestSMTPNotify(t *testing.T) {
+	server := newSMTPServer(t, nil, false)
+	defer server.listener.Close()
+
+	s := newTestSMTP(t, server, Config{"security": "none", "username": "dyngo", "password": "secret"})
+	assert.NoError(t, s.Notify(context.Background(), failedEvent))
+
+	msg := server.next(t)
+	assert.Equal(t, "\x00dyngo\x00secret", msg.auth)
+	assert.Equal(t, "dyngo@domain.com", msg.from)
+	assert.Equal(t, []string{"admin@domain.com", "ops@domain.com"}, msg.to)
+	assert.Contains(t, msg.data, "Subject: dyngo: sync failed\n")
+	assert.Contains(t, msg.data, "To: admin@domain.com, ops@domain.com\n")
+	assert.Contains(t, msg.data, failedEvent.Message()+"\n")
+	assert.Contains(t, msg.data, "Error:    invalid credentials\n")
+	assert.NotContains(t, msg.data, "Old:")
+}
+
+func TestSMTPTemplates(t *testing.T) {
+	server := newSMTPServer(t, nil, false)
+	defer server.listener.Close()
+
+	s := newTestSMTP(t, server, Config{"security": "none",
+		"subject": "[{{.Type}}] {{.Record}}\nBcc: someone@domain.com", "body": "{{.Error}}"})
+	assert.NoError(t, s.Notify(context.Background(), failedEvent))
+
+	msg := server.next(t)
+	assert.Contains(t, msg.data, "Subject: [sync_failed] home.domain.com Bcc: someone@domain.com\n")
+	assert.Contains(t, msg.data, "\n\ninvalid credentials")
+}
+
+func TestSMTPStartTLS(t *testing.T) {
+	serverTLS, roots := testTLS()
+	server := newSMTPServer(t, serverTLS, false)
+	defer server.listener.Close()
+
+	s := newTestSMTP(t, server, Config{"username": "dyngo", "password": "secret"})
+	s.tlsConfig.RootCAs = roots
+	assert.NoError(t, s.Notify(context.Background(), failedEvent))
+	msg := server.next(t)
+	assert.True(t, msg.secure)
+	assert.Equal(t, "\x00dyngo\x00secret", msg.auth)
+
+	// the server certificate is checked
+	s = newTestSMTP(t, server, Config{})
+	assert.Error(t, s.Notify(context.Background(), failedEvent))
+}
+
+func TestSMTPImplicitTLS(t *testing.T) {
+	serverTLS, roots := testTLS()
+	server := newSMTPServer(t, serverTLS, true)
+	defer server.listener.Close()
+
+	s := newTestSMTP(t, server, Config{"security": "tls"})
+	s.tlsConfig.RootCAs = roots
+	assert.NoError(t, s.Notify(context.Background(), failedEvent))
+	assert.True(t, server.next(t).secure)
+}
+
+func TestSMTPRateLimit(t *testing.T) {
+	server := newSMTPServer(t, nil, false)
+	defer server.listener.Close()
+
+	s := newTestSMTP(t, server, Config{"security": "none", "rate_limit": 2})
+	assert.NoError(t, s.Notify(context.Background(), failedEvent))
+	assert.NoError(t, s.Notify(context.Background(), failedEvent))
+	assert.Error(t, s.Notify(context.Background(), failedEvent))
+	server.next(t)
+	server.next(t)
+
+	now := time.Now()
+	s = newTestSMTP(t, server, Config{"rate_limit": 1, "rate_interval": "1m"})
+	assert.True(t, s.allow(now))
+	assert.False(t, s.allow(now.Add(59*time.Second)))
+	assert.True(t, s.allow(now.Add(61*time.Second)))
+}
+
+func TestSMTPDigest(t *testing.T) {
+	server := newSMTPServer(t, nil, false)
+	defer server.listener.Close()
+
+	s := newTestSMTP(t, server, Config{"security": "none", "digest": "100ms", "rate_limit": 1})
+	assert.True(t, s.WantsRepeats())
+	assert.NoError(t, s.Notify(context.Background(), failedEvent))
+	server.next(t)
+
+	// repeats, and events over the rate limit, are sent together
+	repeat := failedEvent
+	repeat.Repeat = true
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, s.Notify(context.Background(), repeat))
+	}
+	assert.NoError(t, s.Notify(context.Background(), Event{Type: IPChanged, Time: time.Now(),
+		RecordType: "A", OldValue: "192.0.2.1", NewValue: "192.0.2.2"}))
+
+	msg := server.next(t)
+	assert.Contains(t, msg.data, "Subject: dyngo: digest of 4 held back events\n")
+	assert.Contains(t, msg.data, failedEvent.Message()+"\n  3 times from")
+	assert.Contains(t, msg.data, "Public IPv4 address changed from 192.0.2.1 to 192.0.2.2\n  1 times from")
+}
+
+func TestSMTPFlush(t *testing.T) {
+	server := newSMTPServer(t, nil, false)
+	defer server.listener.Close()
+
+	s := newTestSMTP(t, server, Config{"security": "none", "digest": "24h"})
+	repeat := failedEvent
+	repeat.Repeat = true
+	assert.NoError(t, s.Notify(context.Background(), repeat))
+
+	// the digest is sent without waiting for it to be due
+	s.Flush()
+	msg := server.next(t)
+	assert.Contains(t, msg.data, "Subject: dyngo: digest of 1 held back events\n")
+	s.mutex.Lock()
+	assert.Nil(t, s.digestTimer)
+	s.mutex.Unlock()
+}
+
+func TestSMTPConfig(t *testing.T) {
+	base := Config{"type": "smtp", "host": "mail.domain.com", "from": "dyngo@domain.com",
+		"to": []interface{}{"admin@domain.com"}}
+	s, err := NewSMTPNotifier(base)
+	assert.NoError(t, err)
+	assert.Equal(t, "mail.domain.com:587", s.address)
+	assert.Equal(t, smtpStartTLS, s.security)
+	assert.False(t, s.WantsRepeats())
+
+	config := Config{"port": 465}
+	for key, value := range base {
+		config[key] = value
+	}
+	s, err = NewSMTPNotifier(config)
+	assert.NoError(t, err)
+	assert.Equal(t, smtpTLS, s.security)
+
+	config = Config{"security": "none"}
+	for key, value := range base {
+		config[key] = value
+	}
+	s, err = NewSMTPNotifier(config)
+	assert.NoError(t, err)
+	assert.Equal(t, "mail.domain.com:25", s.address)
+
+	for _, missing := range []string{"host", "from", "to"} {
+		config := Config{}
+		for key, value := range base {
+			if key != missing {
+				config[key] = value
+			}
+		}
+		_, err := NewSMTPNotifier(config)
+		assert.Error(t, err, missing)
+	}
+	config["security"] = "ssl"
+	_, err = NewSMTPNotifier(config)
+	assert.Error(t, err)
+}
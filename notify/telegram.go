@@ -0,0 +1,85 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const telegramName = "telegram"
+
+const telegramEndpoint = "https://api.telegram.org"
+
+// telegramConfig is the telegram notifier config
+type telegramConfig struct {
+	Token    string `mapstructure:"token"`
+	ChatID   string `mapstructure:"chat_id"`
+	Silent   bool   `mapstructure:"silent"`
+	Endpoint string `mapstructure:"endpoint"`
+}
+
+// Telegram notifier sends events with the Telegram Bot API
+type Telegram struct {
+	name     Name
+	token    string
+	chatID   string
+	silent   bool
+	endpoint string
+	client   *http.Client
+}
+
+// NewTelegramNotifier is Telegram constructor
+func NewTelegramNotifier(config Config) (*Telegram, error) {
+	var c telegramConfig
+	if err := decode(config, &c, true); err != nil {
+		return nil, err
+	}
+	if c.Token == "" {
+		return nil, errors.New("token missing from telegram notifier")
+	}
+	if c.ChatID == "" {
+		return nil, errors.New("chat_id missing from telegram notifier")
+	}
+	if c.Endpoint == "" {
+		c.Endpoint = telegramEndpoint
+	}
+	return &Telegram{
+		name:     telegramName,
+		token:    c.Token,
+		chatID:   c.ChatID,
+		silent:   c.Silent,
+		endpoint: strings.TrimRight(c.Endpoint, "/"),
+		client:   &http.Client{},
+	}, nil
+}
+
+// GetName returns name identifier
+func (t *Telegram) GetName() Name {
+	return t.name
+}
+
+// Notify sends event as an html formatted message
+func (t *Telegram) Notify(ctx context.Context, event Event) error {
+	text := fmt.Sprintf("<b>%s</b>\n%s", html.EscapeString(event.Title()), html.EscapeString(event.Message()))
+	for _, f := range eventFields(event) {
+		text += fmt.Sprintf("\n<b>%s:</b> <code>%s</code>", f.Name, html.EscapeString(f.Value))
+	}
+	payload := map[string]interface{}{
+		"chat_id":              t.chatID,
+		"text":                 text,
+		"parse_mode":           "HTML",
+		"disable_notification": t.silent,
+	}
+	endpoint := fmt.Sprintf("%s/bot%s/sendMessage", t.endpoint, t.token)
+	err := postJSON(ctx, t.client, http.MethodPost, endpoint, payload, nil)
+	// the token is part of the url, keep it out of the logs
+	if uerr, ok := err.(*url.Error); ok {
+		uerr.URL = strings.Replace(uerr.URL, t.token, "REDACTED", -1)
+	}
+	return err
+}
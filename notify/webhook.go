@@ -0,0 +1,101 @@
+package notify
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+const webhookName = "webhook"
+
+// defaultWebhookBody posts the event as json
+const defaultWebhookBody = "{{json .}}"
+
+// webhookConfig is the webhook notifier config
+type webhookConfig struct {
+	URL         string            `mapstructure:"url"`
+	Method      string            `mapstructure:"method"`
+	Headers     map[string]string `mapstructure:"headers"`
+	Body        string            `mapstructure:"body"`
+	ContentType string            `mapstructure:"content_type"`
+}
+
+// Webhook notifier sends events to a url
+type Webhook struct {
+	name        Name
+	url         string
+	method      string
+	headers     map[string]string
+	contentType string
+	body        *template.Template
+	client      *http.Client
+}
+
+// NewWebhookNotifier is Webhook constructor
+func NewWebhookNotifier(config Config) (*Webhook, error) {
+	var c webhookConfig
+	if err := decode(config, &c, true); err != nil {
+		return nil, err
+	}
+	w := &Webhook{
+		name:        webhookName,
+		url:         c.URL,
+		method:      strings.ToUpper(c.Method),
+		headers:     c.Headers,
+		contentType: c.ContentType,
+		client:      &http.Client{},
+	}
+	if w.url == "" {
+		return nil, errors.New("url missing from webhook notifier")
+	}
+	if u, err := url.Parse(w.url); err != nil || u.Host == "" {
+		return nil, errors.New("url is not a valid url in webhook notifier")
+	}
+	if w.method == "" {
+		w.method = http.MethodPost
+	}
+	if c.Body == "" {
+		c.Body = defaultWebhookBody
+		if w.contentType == "" {
+			w.contentType = "application/json"
+		}
+	}
+	var err error
+	if w.body, err = newTemplate("body", c.Body); err != nil {
+		return nil, errors.Wrap(err, "invalid body in webhook notifier")
+	}
+	return w, nil
+}
+
+// GetName returns name identifier
+func (w *Webhook) GetName() Name {
+	return w.name
+}
+
+// Notify sends event to the webhook url
+func (w *Webhook) Notify(ctx context.Context, event Event) error {
+	var body io.Reader
+	if w.method != http.MethodGet {
+		data, err := render(w.body, event)
+		if err != nil {
+			return err
+		}
+		body = strings.NewReader(data)
+	}
+	req, err := http.NewRequest(w.method, w.url, body)
+	if err != nil {
+		return err
+	}
+	if w.contentType != "" {
+		req.Header.Set("Content-Type", w.contentType)
+	}
+	for key, value := range w.headers {
+		req.Header.Set(key, value)
+	}
+	return send(ctx, w.client, req)
+}
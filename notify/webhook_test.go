@@ -0,0 +1,98 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type webhookRequest struct {
+	method  string
+	headers http.Header
+	body    string
+}
+
+func newWebhookServer(status int) (*httptest.Server, chan webhookRequest) {
+	requests := make(chan webhookRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- webhookRequest{method: r.Method, headers: r.Header, body: string(body)}
+		w.WriteHeader(status)
+	}))
+	return server, requests
+}
+
+var updatedEvent = Event{
+	Type:       RecordUpdated,
+	Time:       time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC),
+	Provider:   "cloudflare",
+	Record:     "home.domain.com",
+	RecordType: "A",
+	OldValue:   "192.0.2.1",
+	NewValue:   "192.0.2.2",
+}
+
+func TestWebhookDefaultBody(t *testing.T) {
+	server, requests := newWebhookServer(http.StatusOK)
+	defer server.Close()
+
+	w, err := NewWebhookNotifier(Config{"type": "webhook", "url": server.URL})
+	assert.NoError(t, err)
+	assert.NoError(t, w.Notify(context.Background(), updatedEvent))
+
+	req := <-requests
+	assert.Equal(t, http.MethodPost, req.method)
+	assert.Equal(t, "application/json", req.headers.Get("Content-Type"))
+	var body map[string]string
+	assert.NoError(t, json.Unmarshal([]byte(req.body), &body))
+	assert.Equal(t, "record_updated", body["type"])
+	assert.Equal(t, "192.0.2.2", body["new_value"])
+	assert.Equal(t, "2019-10-01T12:00:00Z", body["time"])
+	assert.Equal(t, updatedEvent.Message(), body["message"])
+}
+
+func TestWebhookTemplate(t *testing.T) {
+	server, requests := newWebhookServer(http.StatusNoContent)
+	defer server.Close()
+
+	w, err := NewWebhookNotifier(Config{
+		"type":         "webhook",
+		"url":          server.URL,
+		"method":       "put",
+		"headers":      map[interface{}]interface{}{"Authorization": "Bearer secret"},
+		"content_type": "text/plain",
+		"body":         "{{.Record}} is now {{.NewValue}}: {{.Message}}",
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, w.Notify(context.Background(), updatedEvent))
+
+	req := <-requests
+	assert.Equal(t, http.MethodPut, req.method)
+	assert.Equal(t, "Bearer secret", req.headers.Get("Authorization"))
+	assert.Equal(t, "text/plain", req.headers.Get("Content-Type"))
+	assert.Equal(t, "home.domain.com is now 192.0.2.2: "+updatedEvent.Message(), req.body)
+}
+
+func TestWebhookErrorStatus(t *testing.T) {
+	server, _ := newWebhookServer(http.StatusInternalServerError)
+	defer server.Close()
+
+	w, err := NewWebhookNotifier(Config{"type": "webhook", "url": server.URL})
+	assert.NoError(t, err)
+	assert.Error(t, w.Notify(context.Background(), updatedEvent))
+}
+
+func TestWebhookConfig(t *testing.T) {
+	_, err := NewWebhookNotifier(Config{"type": "webhook"})
+	assert.Error(t, err)
+	_, err = NewWebhookNotifier(Config{"type": "webhook", "url": "not a url"})
+	assert.Error(t, err)
+	_, err = NewWebhookNotifier(Config{"type": "webhook", "url": "http://localhost/", "body": "{{.Record"})
+	assert.Error(t, err)
+}
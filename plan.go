@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gesquive/dyngo/dns"
+	"github.com/gesquive/dyngo/ipcheck"
+	"github.com/spf13/viper"
+)
+
+// planActions are the words the plan uses for each sync action
+var planActions = map[dns.Action]string{
+	dns.Unchanged: "none",
+	dns.Updated:   "update",
+	dns.Created:   "create",
+	dns.Synced:    "send",
+}
+
+// RunPlan detects the public addresses and prints what a sync would do to
+// each provider record, without changing any of them. It returns false if
+// an address or record could not be read
+func RunPlan(ctx context.Context, dnsProviders dnsProvidersList, out io.Writer) bool {
+	log.Infof("plan: checking records for %d providers, nothing will be changed", len(dnsProviders))
+	report := planDomain(ctx, dnsProviders)
+	printPlan(out, report.Outcomes)
+	if report.Err != nil {
+		log.Errorf("plan: could not get public address err=%s", report.Err)
+	}
+	return !report.Failed()
+}
+
+// planDomain detects the public addresses and reads the current record of
+// each provider, the outcomes are what syncing them would do
+func planDomain(ctx context.Context, dnsProviders dnsProvidersList) (report syncReport) {
+	report.Addresses = map[string]string{}
+	timeout, _ := time.ParseDuration(viper.GetString("service.provider_timeout"))
+	for _, check := range []struct {
+		enabled    bool
+		family     ipcheck.Family
+		recordType string
+	}{
+		{viper.GetBool("ip_check.ipv4"), ipcheck.IPv4, "A"},
+		{viper.GetBool("ip_check.ipv6"), ipcheck.IPv6, "AAAA"},
+	} {
+		if !check.enabled {
+			continue
+		}
+		currentIP, err := getPublicIPAddress(check.family)
+		if err != nil {
+			report.Err = err
+			return report
+		}
+		report.Addresses[check.recordType] = currentIP
+		for _, provider := range dnsProviders {
+			report.Outcomes = append(report.Outcomes,
+				planRecord(ctx, provider, check.recordType, currentIP, timeout))
+		}
+	}
+	return report
+}
+
+// planRecord reads the current record of a provider, giving up after timeout
+func planRecord(ctx context.Context, provider dns.Provider, recordType string,
+	ipAddress string, timeout time.Duration) syncOutcome {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	current, err := provider.CurrentRecord(ctx, recordType)
+	if err != nil {
+		return syncOutcome{Result: dns.NewResult(provider, recordType, ipAddress), Err: err}
+	}
+	return syncOutcome{Result: dns.PlanRecord(provider, recordType, ipAddress, current)}
+}
+
+// printPlan writes the outcomes as a table
+func printPlan(out io.Writer, outcomes []syncOutcome) {
+	table := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "PROVIDER\tRECORD\tTYPE\tCURRENT\tDESIRED\tACTION")
+	for _, outcome := range outcomes {
+		result := outcome.Result
+		current := result.OldValue
+		action := planActions[result.Action]
+		switch {
+		case outcome.Err != nil:
+			current = "?"
+			action = "error: " + outcome.Err.Error()
+		case result.Action == dns.Synced:
+			current = "unknown"
+		case current == "":
+			current = "-"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\n", result.Provider, result.Record,
+			result.RecordType, current, result.NewValue, action)
+	}
+	table.Flush()
+}
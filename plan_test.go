@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunPlan(t *testing.T) {
+	ipServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "198.51.100.7")
+	}))
+	defer ipServer.Close()
+	viper.Set("ip_check.ipv4_urls", []string{ipServer.URL})
+	viper.Set("ip_check.ipv6", false)
+	defer viper.Set("ip_check.ipv4_urls", nil)
+	defer viper.Set("ip_check.ipv6", nil)
+
+	providers := []*fakeProvider{
+		{record: "home.domain.com", updates: map[string]string{"A": "198.51.100.7"}},
+		{record: "work.domain.com", updates: map[string]string{"A": "192.0.2.1"}},
+		{record: "new.domain.com", updates: map[string]string{}},
+	}
+	list := dnsProvidersList{providers[0], providers[1], providers[2]}
+	var out bytes.Buffer
+	assert.True(t, RunPlan(context.Background(), list, &out))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, []string{
+		"PROVIDER  RECORD           TYPE  CURRENT       DESIRED       ACTION",
+		"fake      home.domain.com  A     198.51.100.7  198.51.100.7  none",
+		"fake      work.domain.com  A     192.0.2.1     198.51.100.7  update",
+		"fake      new.domain.com   A     -             198.51.100.7  create",
+	}, lines)
+	for _, provider := range providers {
+		assert.Equal(t, 0, provider.calls, "plan should not sync")
+	}
+	assert.Empty(t, providers[2].updates)
+
+	providers[1].fail = true
+	out.Reset()
+	assert.False(t, RunPlan(context.Background(), list, &out))
+	assert.Contains(t, out.String(), "?             198.51.100.7  error: fake failure")
+}
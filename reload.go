@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gesquive/dyngo/dns"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// reloadDelay gives editors time to finish writing before a reload
+const reloadDelay = 500 * time.Millisecond
+
+// configReloader re-reads the config file and swaps in the new providers
+type configReloader struct {
+	providers atomic.Value
+	settings  atomic.Value
+	lastGood  []byte
+	mutex     sync.Mutex
+}
+
+// apiSettings are the config values the api uses, read when the config is
+// loaded so requests never read viper while a reload is writing it
+type apiSettings struct {
+	recordTypes    []string
+	syncInterval   time.Duration
+	readyIntervals int
+	token          string
+	// shutdownTimeout only changes on a restart
+	shutdownTimeout time.Duration
+}
+
+// readAPISettings reads the api settings from the config
+func readAPISettings() apiSettings {
+	settings := apiSettings{
+		readyIntervals: viper.GetInt("http.ready_intervals"),
+		token:          viper.GetString("http.token"),
+	}
+	if viper.GetBool("ip_check.ipv4") {
+		settings.recordTypes = append(settings.recordTypes, "A")
+	}
+	if viper.GetBool("ip_check.ipv6") {
+		settings.recordTypes = append(settings.recordTypes, "AAAA")
+	}
+	settings.syncInterval, _ = time.ParseDuration(viper.GetString("service.sync_interval"))
+	settings.shutdownTimeout, _ = time.ParseDuration(viper.GetString("service.shutdown_timeout"))
+	return settings
+}
+
+func newConfigReloader(providers dnsProvidersList) *configReloader {
+	r := &configReloader{}
+	r.providers.Store(providers)
+	r.settings.Store(readAPISettings())
+	if path := viper.ConfigFileUsed(); path != "" {
+		r.lastGood, _ = ioutil.ReadFile(path)
+	}
+	return r
+}
+
+// Providers returns the providers of the last valid config
+func (r *configReloader) Providers() dnsProvidersList {
+	return r.providers.Load().(dnsProvidersList)
+}
+
+// Settings returns the api settings of the last valid config
+func (r *configReloader) Settings() apiSettings {
+	return r.settings.Load().(apiSettings)
+}
+
+// Reload re-reads the config file, the running config is kept if any part
+// of the new one is not valid. It waits for a running sync so providers are only
+// swapped between sync cycles
+func (r *configReloader) Reload() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	path := viper.ConfigFileUsed()
+	if path == "" {
+		return errors.New("no config file in use")
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	syncCycle <- struct{}{}
+	defer func() { <-syncCycle }()
+
+	config, err := r.load(data)
+	if err != nil {
+		if r.lastGood != nil {
+			viper.ReadConfig(bytes.NewReader(r.lastGood))
+		}
+		return err
+	}
+	r.lastGood = data
+	retries.SetPolicies(config.policies)
+	config.notifications.apply()
+	config.hooks.apply()
+	r.providers.Store(config.providers)
+	// sync_interval and shutdown_timeout need a restart to change
+	settings := readAPISettings()
+	settings.syncInterval = r.Settings().syncInterval
+	settings.shutdownTimeout = r.Settings().shutdownTimeout
+	r.settings.Store(settings)
+	log.Infof("config: reloaded %s, found %d dns providers", path, len(config.providers))
+	return nil
+}
+
+// loadedConfig is what a reload swaps in, once all of it is valid
+type loadedConfig struct {
+	providers     dnsProvidersList
+	policies      map[dns.Provider]retryPolicy
+	notifications notificationsConfig
+	hooks         hooksConfig
+}
+
+// load reads data in as the config, and returns its providers, notifiers
+// and hooks without using any of them
+func (r *configReloader) load(data []byte) (config loadedConfig, err error) {
+	if err = viper.ReadConfig(bytes.NewReader(data)); err != nil {
+		return config, err
+	}
+	if err = validateConfig(); err != nil {
+		return config, err
+	}
+	config.providers, config.policies, err = readDNSProviders()
+	if err != nil {
+		return config, errors.Wrap(err, "could not parse dns_providers")
+	}
+	if len(config.providers) == 0 {
+		return config, errors.New("no providers found")
+	}
+	if config.notifications, err = readNotifications(); err != nil {
+		return config, errors.Wrap(err, "could not parse notifications")
+	}
+	if config.hooks, err = readHooks(); err != nil {
+		return config, errors.Wrap(err, "could not parse hooks")
+	}
+	return config, nil
+}
+
+// watchReload reloads the config on SIGHUP, and on changes to the config
+// file when watch is set, until ctx is canceled
+func watchReload(ctx context.Context, reloader *configReloader, watch bool) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	var changes <-chan fsnotify.Event
+	if watch {
+		watcher, err := watchConfigFile()
+		if err != nil {
+			log.Errorf("config: could not watch the config file: %v", err)
+		} else {
+			defer watcher.Close()
+			changes = watcher.Events
+		}
+	}
+
+	// changes are collected until the file has been quiet for reloadDelay
+	delay := time.NewTimer(reloadDelay)
+	delay.Stop()
+	for {
+		select {
+		case <-signals:
+			log.Infof("config: received SIGHUP, reloading")
+			reload(reloader)
+		case event := <-changes:
+			if filepath.Clean(event.Name) == filepath.Clean(viper.ConfigFileUsed()) {
+				delay.Reset(reloadDelay)
+			}
+		case <-delay.C:
+			log.Infof("config: file changed, reloading")
+			reload(reloader)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// ignoreReload logs and ignores SIGHUP until ctx is canceled, for the
+// commands that can not reload their config. Otherwise a reload signal sent
+// to them would kill them
+func ignoreReload(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-signals:
+				log.Warnf("config: received SIGHUP, the config is only reloaded when running as a service")
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func reload(reloader *configReloader) {
+	if err := reloader.Reload(); err != nil {
+		log.Errorf("config: could not reload, keeping the running config: %v", err)
+	}
+}
+
+// watchConfigFile watches the directory of the config file, since editors
+// often replace the file instead of writing to it
+func watchConfigFile() (*fsnotify.Watcher, error) {
+	path := viper.ConfigFileUsed()
+	if path == "" {
+		return nil, errors.New("no config file in use")
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	log.Debugf("config: watching %s for changes", path)
+	return watcher, nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gesquive/dyngo/notify"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+const reloadConfig = `dns_providers:
+  - name: custom
+    path: /bin/true
+    record: home.domain.com
+`
+
+func writeTestConfig(t *testing.T, path string, config string) {
+	assert.NoError(t, ioutil.WriteFile(path, []byte(config), 0600))
+}
+
+func newTestReloader(t *testing.T) (*configReloader, string, func()) {
+	dir, err := ioutil.TempDir("", "dyngo")
+	assert.NoError(t, err)
+	path := filepath.Join(dir, "config.yml")
+	writeTestConfig(t, path, reloadConfig)
+
+	viper.SetConfigFile(path)
+	viper.SetConfigType("yaml")
+	assert.NoError(t, viper.ReadInConfig())
+	providers, err := getDNSProviders()
+	assert.NoError(t, err)
+
+	return newConfigReloader(providers), path, func() {
+		viper.SetConfigFile("")
+		viper.ReadConfig(bytes.NewBufferString("{}"))
+		os.RemoveAll(dir)
+	}
+}
+
+func TestReload(t *testing.T) {
+	reloader, path, cleanup := newTestReloader(t)
+	defer cleanup()
+	assert.Len(t, reloader.Providers(), 1)
+
+	writeTestConfig(t, path, reloadConfig+`  - name: custom
+    path: /bin/true
+    record: other.domain.com
+`)
+	assert.NoError(t, reloader.Reload())
+	assert.Len(t, reloader.Providers(), 2)
+	assert.Equal(t, "other.domain.com", reloader.Providers()[1].GetRecord())
+}
+
+func TestReloadSettings(t *testing.T) {
+	reloader, path, cleanup := newTestReloader(t)
+	defer cleanup()
+	assert.Equal(t, "", reloader.Settings().token)
+	syncInterval := reloader.Settings().syncInterval
+
+	writeTestConfig(t, path, reloadConfig+`http:
+  token: secret
+service:
+  sync_interval: 5m
+`)
+	assert.NoError(t, reloader.Reload())
+	assert.Equal(t, "secret", reloader.Settings().token)
+	assert.Equal(t, syncInterval, reloader.Settings().syncInterval, "sync_interval needs a restart")
+}
+
+func TestReloadKeepsValidConfig(t *testing.T) {
+	reloader, path, cleanup := newTestReloader(t)
+	defer cleanup()
+
+	writeTestConfig(t, path, `dns_providers:
+  - name: nope
+`)
+	assert.Error(t, reloader.Reload())
+	assert.Len(t, reloader.Providers(), 1)
+
+	// the running config is restored as well
+	providers, err := getDNSProviders()
+	assert.NoError(t, err)
+	assert.Len(t, providers, 1)
+}
+
+func TestReloadRetryPolicies(t *testing.T) {
+	reloader, path, cleanup := newTestReloader(t)
+	defer cleanup()
+
+	// the policies of replaced providers are dropped
+	writeTestConfig(t, path, `dns_providers:
+  - name: custom
+    path: /bin/true
+    record: other.domain.com
+    retry_attempts: 5
+`)
+	assert.NoError(t, reloader.Reload())
+	assert.Len(t, retries.Policies(), 1)
+	provider := reloader.Providers()[0]
+	assert.Equal(t, 5, retries.Policy(provider).Attempts)
+
+	// and kept when the new config is not valid
+	writeTestConfig(t, path, reloadConfig+`notifications:
+  notifiers:
+    - type: nope
+`)
+	assert.Error(t, reloader.Reload())
+	assert.Len(t, retries.Policies(), 1)
+	assert.Equal(t, 5, retries.Policy(provider).Attempts)
+}
+
+func TestReloadIsAllOrNothing(t *testing.T) {
+	reloader, path, cleanup := newTestReloader(t)
+	defer cleanup()
+	notifier := useFakeNotifier()
+	defer func() { notifications = notify.NewDispatcher() }()
+	provider := reloader.Providers()[0]
+	attempts := retries.Policy(provider).Attempts
+
+	// the notifiers and providers are valid, but the hooks are not
+	writeTestConfig(t, path, `dns_providers:
+  - name: custom
+    path: /bin/true
+    record: other.domain.com
+    retry_attempts: 9
+notifications:
+  notifiers:
+    - type: webhook
+      url: http://localhost/hook
+hooks:
+  timeout: soon
+`)
+	assert.Error(t, reloader.Reload())
+	assert.Equal(t, provider, reloader.Providers()[0])
+	assert.Equal(t, attempts, retries.Policy(provider).Attempts)
+	notifications.Dispatch(notify.Event{Type: notify.IPChanged})
+	assert.Len(t, notifier.Events(), 1, "the notifiers should not change")
+}
+
+func TestReloadOnFileChange(t *testing.T) {
+	reloader, path, cleanup := newTestReloader(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		watchReload(ctx, reloader, true)
+		close(stopped)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	writeTestConfig(t, path, reloadConfig+`  - name: custom
+    path: /bin/true
+    record: other.domain.com
+`)
+	for i := 0; i < 30 && len(reloader.Providers()) != 2; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.Len(t, reloader.Providers(), 2)
+}
+
+func TestIgnoreReload(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ignoreReload(ctx)
+
+	// without the handler the signal would kill the test
+	assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+	time.Sleep(50 * time.Millisecond)
+}
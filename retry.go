@@ -0,0 +1,181 @@
+package main
+
+import (
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gesquive/dyngo/dns"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// retryPolicy says how often and how quickly a failed provider sync is retried
+type retryPolicy struct {
+	// Attempts is the total number of tries, including the first one
+	Attempts       int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Jitter is the fraction each backoff is randomly changed by
+	Jitter float64
+}
+
+// backoff returns how long to wait before the next try, after the given
+// number of failed attempts
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	delay := p.InitialBackoff
+	for i := 1; i < attempt && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		delay += time.Duration(float64(delay) * p.Jitter * (rand.Float64()*2 - 1))
+	}
+	return delay
+}
+
+// getRetryPolicy returns the service.retry policy, with any retry_* options
+// in the provider config taking precedence
+func getRetryPolicy(config dns.ProviderConfig) (retryPolicy, error) {
+	policy := retryPolicy{
+		Attempts: viper.GetInt("service.retry.attempts"),
+		Jitter:   viper.GetFloat64("service.retry.jitter"),
+	}
+	var err error
+	if policy.InitialBackoff, err = time.ParseDuration(viper.GetString("service.retry.initial_backoff")); err != nil {
+		return policy, errors.Wrap(err, "invalid service.retry.initial_backoff")
+	}
+	if policy.MaxBackoff, err = time.ParseDuration(viper.GetString("service.retry.max_backoff")); err != nil {
+		return policy, errors.Wrap(err, "invalid service.retry.max_backoff")
+	}
+
+	if value, ok := config["retry_attempts"]; ok && value != "" {
+		if policy.Attempts, err = strconv.Atoi(value); err != nil {
+			return policy, errors.Wrap(err, "invalid retry_attempts")
+		}
+	}
+	if value, ok := config["retry_initial_backoff"]; ok && value != "" {
+		if policy.InitialBackoff, err = time.ParseDuration(value); err != nil {
+			return policy, errors.Wrap(err, "invalid retry_initial_backoff")
+		}
+	}
+	if value, ok := config["retry_max_backoff"]; ok && value != "" {
+		if policy.MaxBackoff, err = time.ParseDuration(value); err != nil {
+			return policy, errors.Wrap(err, "invalid retry_max_backoff")
+		}
+	}
+	// a jitter over 1 could make a delay zero or negative, and retry hot
+	if policy.Jitter < 0 || policy.Jitter > 1 {
+		return policy, errors.Errorf("service.retry.jitter must be between 0 and 1, got %g", policy.Jitter)
+	}
+	if policy.InitialBackoff <= 0 {
+		return policy, errors.Errorf("retry initial_backoff must be positive, got %s", policy.InitialBackoff)
+	}
+	if policy.MaxBackoff <= 0 {
+		return policy, errors.Errorf("retry max_backoff must be positive, got %s", policy.MaxBackoff)
+	}
+	if policy.MaxBackoff < policy.InitialBackoff {
+		policy.MaxBackoff = policy.InitialBackoff
+	}
+	return policy, nil
+}
+
+// retryScheduler runs delayed retries of failed provider syncs
+type retryScheduler struct {
+	policies map[dns.Provider]retryPolicy
+	timers   map[string]*time.Timer
+	pending  sync.WaitGroup
+	stopped  bool
+	mutex    sync.Mutex
+}
+
+// retries holds the retries of the running service
+var retries = newRetryScheduler()
+
+func newRetryScheduler() *retryScheduler {
+	return &retryScheduler{
+		policies: map[dns.Provider]retryPolicy{},
+		timers:   map[string]*time.Timer{},
+	}
+}
+
+// SetPolicies replaces the retry policies, policies is not modified after
+// it is set so providers that are no longer configured are dropped
+func (r *retryScheduler) SetPolicies(policies map[dns.Provider]retryPolicy) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.policies = policies
+}
+
+// Policies returns the retry policies of every provider
+func (r *retryScheduler) Policies() map[dns.Provider]retryPolicy {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.policies
+}
+
+// Policy returns the retry policy of a provider, providers without one
+// are never retried
+func (r *retryScheduler) Policy(provider dns.Provider) retryPolicy {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.policies[provider]
+}
+
+// Schedule runs retry after delay, replacing any retry pending for key
+func (r *retryScheduler) Schedule(key string, delay time.Duration, retry func()) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.cancel(key)
+	if r.stopped {
+		return
+	}
+
+	var timer *time.Timer
+	r.pending.Add(1)
+	timer = time.AfterFunc(delay, func() {
+		defer r.pending.Done()
+		r.mutex.Lock()
+		if r.timers[key] == timer {
+			delete(r.timers, key)
+		}
+		r.mutex.Unlock()
+		retry()
+	})
+	r.timers[key] = timer
+}
+
+// Cancel drops the retry pending for key
+func (r *retryScheduler) Cancel(key string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.cancel(key)
+}
+
+func (r *retryScheduler) cancel(key string) {
+	if timer, ok := r.timers[key]; ok {
+		if timer.Stop() {
+			r.pending.Done()
+		}
+		delete(r.timers, key)
+	}
+}
+
+// Stop drops every pending retry and refuses new ones, retries that are
+// already running are left to finish
+func (r *retryScheduler) Stop() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.stopped = true
+	for key := range r.timers {
+		r.cancel(key)
+	}
+}
+
+// Wait blocks until all pending retries, and any they schedule, are done
+func (r *retryScheduler) Wait() {
+	r.pending.Wait()
+}
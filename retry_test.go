@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gesquive/dyngo/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	policy := retryPolicy{Attempts: 5, InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	assert.Equal(t, time.Second, policy.backoff(1))
+	assert.Equal(t, 2*time.Second, policy.backoff(2))
+	assert.Equal(t, 4*time.Second, policy.backoff(3))
+	assert.Equal(t, 5*time.Second, policy.backoff(4))
+	assert.Equal(t, 5*time.Second, policy.backoff(20))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		delay := policy.backoff(2)
+		assert.True(t, delay >= time.Second && delay <= 3*time.Second, delay)
+	}
+}
+
+func TestGetRetryPolicy(t *testing.T) {
+	policy, err := getRetryPolicy(dns.ProviderConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, retryPolicy{Attempts: 3, InitialBackoff: 10 * time.Second,
+		MaxBackoff: 5 * time.Minute, Jitter: 0.2}, policy)
+
+	policy, err = getRetryPolicy(dns.ProviderConfig{
+		"retry_attempts":        "5",
+		"retry_initial_backoff": "1s",
+		"retry_max_backoff":     "1m",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, policy.Attempts)
+	assert.Equal(t, time.Second, policy.InitialBackoff)
+	assert.Equal(t, time.Minute, policy.MaxBackoff)
+
+	_, err = getRetryPolicy(dns.ProviderConfig{"retry_attempts": "many"})
+	assert.Error(t, err)
+}
+
+func TestRetrySchedulerCancel(t *testing.T) {
+	scheduler := newRetryScheduler()
+	runs := 0
+	scheduler.Schedule("key", time.Hour, func() { runs++ })
+	scheduler.Schedule("key", time.Millisecond, func() { runs += 10 })
+	scheduler.Schedule("other", time.Hour, func() { runs += 100 })
+	scheduler.Cancel("other")
+	scheduler.Wait()
+	assert.Equal(t, 10, runs)
+}
+
+func TestSyncRecordsRetriesFailures(t *testing.T) {
+	state, _ := loadState("")
+	flaky := &fakeProvider{record: "flaky.domain.com", failures: 2, updates: map[string]string{}}
+	broken := &fakeProvider{record: "broken.domain.com", failures: 5, permanent: true,
+		updates: map[string]string{}}
+	policy := retryPolicy{Attempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	retries.SetPolicies(map[dns.Provider]retryPolicy{flaky: policy, broken: policy})
+
+	assert.True(t, anyFailed(syncRecords(context.Background(), dnsProvidersList{flaky, broken},
+		state, "A", "192.0.2.1", false)))
+	retries.Wait()
+
+	assert.Equal(t, 3, flaky.calls)
+	assert.Equal(t, "192.0.2.1", state.Get(flaky, "A"))
+	assert.Equal(t, 1, broken.calls, "permanent errors should not be retried")
+	assert.Equal(t, "", state.Get(broken, "A"))
+}
+
+func TestRetryDroppedAfterAddressChange(t *testing.T) {
+	defer func(s *serviceStatus) { status = s }(status)
+	status = newServiceStatus()
+	state, _ := loadState("")
+	flaky := &fakeProvider{record: "flaky.domain.com", failures: 1, updates: map[string]string{}}
+	retries.SetPolicies(map[dns.Provider]retryPolicy{flaky: {Attempts: 3,
+		InitialBackoff: 50 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}})
+
+	// the retry fires while a later sync of a new address is running
+	observeAddress("A", "192.0.2.1")
+	syncRecords(context.Background(), dnsProvidersList{flaky}, state, "A", "192.0.2.1", false)
+	syncCycle <- struct{}{}
+	time.Sleep(100 * time.Millisecond)
+	observeAddress("A", "192.0.2.2")
+	flaky.updates["A"] = "192.0.2.2"
+	state.Set(flaky, "A", "192.0.2.2")
+	<-syncCycle
+	retries.Wait()
+
+	assert.Equal(t, 1, flaky.calls, "the old address should not be retried")
+	assert.Equal(t, "192.0.2.2", flaky.updates["A"])
+}
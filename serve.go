@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gesquive/dyngo/dns"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// ServeCmd runs dyngo as a DynDNS2 update server
+var ServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Accept DynDNS2 updates and pass them to the dns providers",
+	Long: `Run a DynDNS2 compatible update server. Clients (ie. routers) push
+their address to /nic/update and the matching dns providers are updated`,
+	Run: serve,
+}
+
+func init() {
+	RootCmd.AddCommand(ServeCmd)
+
+	ServeCmd.Flags().StringP("listen", "l", ":8245",
+		"The address to listen on for updates")
+
+	viper.BindEnv("server.listen", "DYNGO_LISTEN")
+	viper.BindPFlag("server.listen", ServeCmd.Flags().Lookup("listen"))
+
+	viper.SetDefault("server.listen", ":8245")
+}
+
+// updateUser is a client allowed to send updates for the given hostnames
+type updateUser struct {
+	Username  string   `mapstructure:"username"`
+	Password  string   `mapstructure:"password"`
+	Hostnames []string `mapstructure:"hostnames"`
+}
+
+// allowed returns true if the user may update the given hostname
+func (u updateUser) allowed(hostname string) bool {
+	for _, allowed := range u.Hostnames {
+		if strings.EqualFold(allowed, hostname) {
+			return true
+		}
+	}
+	return false
+}
+
+// updateServer handles DynDNS2 update requests, updates of the same
+// hostname run one at a time
+type updateServer struct {
+	users     map[string]updateUser
+	providers dnsProvidersList
+	timeout   time.Duration
+	lastIP    map[string]string
+	hostLocks map[string]*sync.Mutex
+	mutex     sync.Mutex
+}
+
+func newUpdateServer(users []updateUser, providers dnsProvidersList) *updateServer {
+	s := &updateServer{
+		users:     map[string]updateUser{},
+		providers: providers,
+		lastIP:    map[string]string{},
+		hostLocks: map[string]*sync.Mutex{},
+	}
+	s.timeout, _ = time.ParseDuration(viper.GetString("service.provider_timeout"))
+	for _, user := range users {
+		s.users[user.Username] = user
+	}
+	return s
+}
+
+func serve(cmd *cobra.Command, args []string) {
+	logFile := openLogFile()
+	if logFile != nil {
+		defer logFile.Close()
+	}
+
+	log.Debugf("config: file=%s", viper.ConfigFileUsed())
+	dns.IntializeLogging(log)
+	dnsProviders, err := getDNSProviders()
+	if err != nil {
+		log.Errorf("could not parse dns_providers: %v", err)
+	}
+	log.Debugf("config: found %d dns providers", len(dnsProviders))
+	if len(dnsProviders) == 0 {
+		log.Errorf("no providers found, exiting")
+		os.Exit(5)
+	}
+
+	var users []updateUser
+	if err := viper.UnmarshalKey("server.users", &users); err != nil {
+		log.Errorf("could not parse server.users: %v", err)
+	}
+	log.Debugf("config: found %d server users", len(users))
+	if len(users) == 0 {
+		log.Errorf("no server users found, exiting")
+		os.Exit(5)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/nic/update", newUpdateServer(users, dnsProviders))
+
+	listen := viper.GetString("server.listen")
+	certFile := viper.GetString("server.tls_cert")
+	keyFile := viper.GetString("server.tls_key")
+	server := &http.Server{Addr: listen, Handler: mux}
+	stopped := make(chan error, 1)
+	go func() {
+		log.Infof("server: listening for updates on %s", listen)
+		if certFile != "" && keyFile != "" {
+			stopped <- server.ListenAndServeTLS(certFile, keyFile)
+		} else {
+			stopped <- server.ListenAndServe()
+		}
+	}()
+
+	ctx, cancel := shutdownContext()
+	defer cancel()
+	ignoreReload(ctx)
+	select {
+	case err = <-stopped:
+		log.Errorf("server: stopped listening: %v", err)
+		os.Exit(1)
+	case <-ctx.Done():
+	}
+
+	// let updates that are in flight finish before exiting
+	shutdownTimeout, _ := time.ParseDuration(viper.GetString("service.shutdown_timeout"))
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Warnf("server: updates still running after %s: %v", shutdownTimeout, err)
+	}
+	log.Infof("server: stopped")
+}
+
+func (s *updateServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+
+	username, password, ok := r.BasicAuth()
+	user, found := s.users[username]
+	if !ok || !found ||
+		subtle.ConstantTimeCompare([]byte(password), []byte(user.Password)) != 1 {
+		log.Warnf("server: bad auth from %s user='%s'", r.RemoteAddr, username)
+		w.Header().Set("WWW-Authenticate", `Basic realm="dyngo"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprintln(w, "badauth")
+		return
+	}
+
+	query := r.URL.Query()
+	ipv4Address, ipv6Address, ok := requestAddresses(r)
+	if !ok {
+		log.Warnf("server: bad address from %s myip='%s' myipv6='%s'",
+			r.RemoteAddr, query.Get("myip"), query.Get("myipv6"))
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(w, "badip")
+		return
+	}
+
+	hostnames := strings.Split(query.Get("hostname"), ",")
+	for _, hostname := range hostnames {
+		hostname = strings.TrimSpace(hostname)
+		if hostname == "" {
+			fmt.Fprintln(w, "notfqdn")
+			continue
+		}
+		if !user.allowed(hostname) {
+			log.Warnf("server: user '%s' is not allowed to update hostname=%s", username, hostname)
+			fmt.Fprintln(w, "nohost")
+			continue
+		}
+		fmt.Fprintln(w, s.update(r.Context(), hostname, ipv4Address, ipv6Address))
+	}
+}
+
+// update syncs the providers of hostname and returns the DynDNS2 answer
+func (s *updateServer) update(ctx context.Context, hostname string, ipv4Address string, ipv6Address string) string {
+	lock := s.hostLock(hostname)
+	lock.Lock()
+	defer lock.Unlock()
+
+	var providers dnsProvidersList
+	for _, provider := range s.providers {
+		if strings.EqualFold(provider.GetRecord(), hostname) {
+			providers = append(providers, provider)
+		}
+	}
+	if len(providers) == 0 {
+		log.Warnf("server: no providers found for hostname=%s", hostname)
+		return "nohost"
+	}
+
+	changed := false
+	addresses := []string{}
+	for _, address := range []struct {
+		recordType string
+		ip         string
+	}{{"A", ipv4Address}, {"AAAA", ipv6Address}} {
+		if address.ip == "" {
+			continue
+		}
+		addresses = append(addresses, address.ip)
+		key := strings.ToLower(hostname) + "/" + address.recordType
+		if s.getLastIP(key) == address.ip {
+			continue
+		}
+
+		log.Infof("server: updating hostname=%s type=%s ip=%s", hostname, address.recordType, address.ip)
+		for _, provider := range providers {
+			result, err := s.syncProvider(ctx, provider, address.recordType, address.ip)
+			if err != nil {
+				log.Errorf("server: provider %s failed to update hostname=%s: %v",
+					provider.GetName(), hostname, err)
+				return "911"
+			}
+			if result.Action != dns.Unchanged {
+				changed = true
+			}
+		}
+		s.setLastIP(key, address.ip)
+	}
+
+	if changed {
+		return "good " + strings.Join(addresses, ",")
+	}
+	return "nochg " + strings.Join(addresses, ",")
+}
+
+// hostLock returns the lock that serializes updates of hostname
+func (s *updateServer) hostLock(hostname string) *sync.Mutex {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	key := strings.ToLower(hostname)
+	lock, ok := s.hostLocks[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.hostLocks[key] = lock
+	}
+	return lock
+}
+
+func (s *updateServer) getLastIP(key string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.lastIP[key]
+}
+
+func (s *updateServer) setLastIP(key string, ipAddress string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lastIP[key] = ipAddress
+}
+
+// syncProvider syncs the record of provider, giving up after the
+// provider_timeout
+func (s *updateServer) syncProvider(ctx context.Context, provider dns.Provider, recordType string,
+	ipAddress string) (dns.Result, error) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	return provider.SyncRecord(ctx, recordType, ipAddress)
+}
+
+// requestAddresses returns the addresses given by the client, falling back
+// to the remote address when none are given
+func requestAddresses(r *http.Request) (ipv4Address string, ipv6Address string, ok bool) {
+	query := r.URL.Query()
+	addresses := []string{query.Get("myip"), query.Get("myipv6")}
+	if addresses[0] == "" && addresses[1] == "" {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return "", "", false
+		}
+		addresses[0] = host
+	}
+
+	for _, address := range addresses {
+		if address == "" {
+			continue
+		}
+		ip := net.ParseIP(address)
+		if ip == nil {
+			return "", "", false
+		}
+		if ip.To4() != nil {
+			ipv4Address = ip.String()
+		} else {
+			ipv6Address = ip.String()
+		}
+	}
+	return ipv4Address, ipv6Address, true
+}
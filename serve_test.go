@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gesquive/dyngo/dns"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeProvider struct {
+	record    string
+	fail      bool
+	failures  int
+	permanent bool
+	delay     time.Duration
+	calls     int
+	updates   map[string]string
+}
+
+func (f *fakeProvider) SyncRecord(ctx context.Context, recordType string, ipAddress string) (dns.Result, error) {
+	result := dns.NewResult(f, recordType, ipAddress)
+	f.calls++
+	if f.delay > 0 {
+		select {
+		case <-time.After(f.delay):
+		case <-ctx.Done():
+			return result, ctx.Err()
+		}
+	}
+	if f.fail || f.calls <= f.failures {
+		if f.permanent {
+			return result, dns.Permanent(errors.New("fake failure"))
+		}
+		return result, errors.New("fake failure")
+	}
+	result.OldValue = f.updates[recordType]
+	result.Action = dns.Updated
+	if result.OldValue == ipAddress {
+		result.Action = dns.Unchanged
+	}
+	f.updates[recordType] = ipAddress
+	return result, nil
+}
+
+func (f *fakeProvider) CurrentRecord(ctx context.Context, recordType string) (dns.Record, error) {
+	if f.fail {
+		return dns.Record{}, errors.New("fake failure")
+	}
+	value, ok := f.updates[recordType]
+	if !ok {
+		return dns.Record{}, nil
+	}
+	return dns.Record{Values: []string{value}}, nil
+}
+
+func (f *fakeProvider) GetName() dns.Name {
+	return "fake"
+}
+
+func (f *fakeProvider) GetRecord() string {
+	return f.record
+}
+
+func newTestUpdateServer(providers ...*fakeProvider) *updateServer {
+	list := dnsProvidersList{}
+	for _, provider := range providers {
+		list = append(list, provider)
+	}
+	return newUpdateServer([]updateUser{{
+		Username:  "router",
+		Password:  "secret",
+		Hostnames: []string{"home.domain.com", "other.domain.com"},
+	}}, list)
+}
+
+func sendUpdate(server *updateServer, user string, pass string, query string) (int, string) {
+	req := httptest.NewRequest(http.MethodGet, "/nic/update?"+query, nil)
+	req.RemoteAddr = "192.0.2.10:54321"
+	req.SetBasicAuth(user, pass)
+	res := httptest.NewRecorder()
+	server.ServeHTTP(res, req)
+	return res.Code, strings.TrimSpace(res.Body.String())
+}
+
+func TestServeBadAuth(t *testing.T) {
+	server := newTestUpdateServer()
+	code, body := sendUpdate(server, "router", "wrong", "hostname=home.domain.com")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, "badauth", body)
+}
+
+func TestServeUpdate(t *testing.T) {
+	provider := &fakeProvider{record: "home.domain.com", updates: map[string]string{}}
+	server := newTestUpdateServer(provider)
+
+	_, body := sendUpdate(server, "router", "secret",
+		"hostname=home.domain.com&myip=198.51.100.1&myipv6=2001:db8::1")
+	assert.Equal(t, "good 198.51.100.1,2001:db8::1", body)
+	assert.Equal(t, "198.51.100.1", provider.updates["A"])
+	assert.Equal(t, "2001:db8::1", provider.updates["AAAA"])
+
+	_, body = sendUpdate(server, "router", "secret",
+		"hostname=home.domain.com&myip=198.51.100.1&myipv6=2001:db8::1")
+	assert.Equal(t, "nochg 198.51.100.1,2001:db8::1", body)
+}
+
+func TestServeRemoteAddress(t *testing.T) {
+	provider := &fakeProvider{record: "home.domain.com", updates: map[string]string{}}
+	server := newTestUpdateServer(provider)
+
+	_, body := sendUpdate(server, "router", "secret", "hostname=home.domain.com")
+	assert.Equal(t, "good 192.0.2.10", body)
+	assert.Equal(t, "192.0.2.10", provider.updates["A"])
+}
+
+func TestServeNoHost(t *testing.T) {
+	provider := &fakeProvider{record: "home.domain.com", updates: map[string]string{}}
+	server := newTestUpdateServer(provider)
+
+	_, body := sendUpdate(server, "router", "secret",
+		"hostname=denied.domain.com,other.domain.com&myip=198.51.100.1")
+	assert.Equal(t, "nohost\nnohost", body)
+	assert.Empty(t, provider.updates)
+}
+
+func TestServeProviderFailure(t *testing.T) {
+	provider := &fakeProvider{record: "home.domain.com", fail: true}
+	server := newTestUpdateServer(provider)
+
+	_, body := sendUpdate(server, "router", "secret", "hostname=home.domain.com&myip=198.51.100.1")
+	assert.Equal(t, "911", body)
+}
+
+func TestServeProviderTimeout(t *testing.T) {
+	viper.Set("service.provider_timeout", "10ms")
+	defer viper.Set("service.provider_timeout", nil)
+	provider := &fakeProvider{record: "home.domain.com", delay: time.Minute, updates: map[string]string{}}
+	server := newTestUpdateServer(provider)
+
+	_, body := sendUpdate(server, "router", "secret", "hostname=home.domain.com&myip=198.51.100.1")
+	assert.Equal(t, "911", body)
+}
+
+func TestServeConcurrentHostnames(t *testing.T) {
+	slow := &fakeProvider{record: "home.domain.com", delay: time.Second, updates: map[string]string{}}
+	other := &fakeProvider{record: "other.domain.com", updates: map[string]string{}}
+	server := newTestUpdateServer(slow, other)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sendUpdate(server, "router", "secret", "hostname=home.domain.com&myip=198.51.100.1")
+	}()
+	defer func() { <-done }()
+	time.Sleep(50 * time.Millisecond)
+
+	// a slow provider does not hold up updates of other hostnames
+	start := time.Now()
+	_, body := sendUpdate(server, "router", "secret", "hostname=other.domain.com&myip=198.51.100.1")
+	assert.Equal(t, "good 198.51.100.1", body)
+	assert.True(t, time.Since(start) < 500*time.Millisecond)
+}
+
+func TestServeBadAddress(t *testing.T) {
+	server := newTestUpdateServer()
+	code, body := sendUpdate(server, "router", "secret", "hostname=home.domain.com&myip=nope")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "badip", body)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gesquive/dyngo/dns"
+)
+
+// recordState is the last address successfully synced to a record
+type recordState struct {
+	Provider dns.Name  `json:"provider"`
+	Record   string    `json:"record"`
+	Type     string    `json:"type"`
+	Address  string    `json:"address"`
+	Synced   time.Time `json:"synced"`
+}
+
+// syncState tracks what was last synced, so unchanged records can be
+// skipped without calling the provider
+type syncState struct {
+	Records    map[string]recordState `json:"records"`
+	LastVerify time.Time              `json:"last_verify"`
+	path       string
+	mutex      sync.Mutex
+}
+
+// loadState reads the state file at path, a missing file returns an empty
+// state and an empty path keeps the state in memory only
+func loadState(path string) (*syncState, error) {
+	state := &syncState{
+		Records: map[string]recordState{},
+		path:    path,
+	}
+	if path == "" {
+		return state, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return state, err
+	}
+	if err = json.Unmarshal(data, state); err != nil {
+		return state, err
+	}
+	if state.Records == nil {
+		state.Records = map[string]recordState{}
+	}
+	return state, nil
+}
+
+func stateKey(provider dns.Provider, recordType string) string {
+	return string(provider.GetName()) + "/" + provider.GetRecord() + "/" + recordType
+}
+
+// Get returns the last address synced to the providers record
+func (s *syncState) Get(provider dns.Provider, recordType string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.Records[stateKey(provider, recordType)].Address
+}
+
+// Set records a successful sync of address to the providers record
+func (s *syncState) Set(provider dns.Provider, recordType string, address string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Records[stateKey(provider, recordType)] = recordState{
+		Provider: provider.GetName(),
+		Record:   provider.GetRecord(),
+		Type:     recordType,
+		Address:  address,
+		Synced:   time.Now(),
+	}
+}
+
+// LastAddress returns the address most recently synced to any recordType
+// record
+func (s *syncState) LastAddress(recordType string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var last recordState
+	for _, record := range s.Records {
+		if record.Type == recordType && record.Synced.After(last.Synced) {
+			last = record
+		}
+	}
+	return last.Address
+}
+
+// Forget removes the providers record, so the next sync calls the provider
+func (s *syncState) Forget(provider dns.Provider, recordType string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.Records, stateKey(provider, recordType))
+}
+
+// NeedsVerify returns true when a full reconciliation is due
+func (s *syncState) NeedsVerify(verifyInterval time.Duration) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return time.Since(s.LastVerify) >= verifyInterval
+}
+
+// Verified records that a full reconciliation was done
+func (s *syncState) Verified() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.LastVerify = time.Now()
+}
+
+// Save writes the state file, the file is replaced atomically so a crash
+// can not leave it half written
+func (s *syncState) Save() error {
+	if s.path == "" {
+		return nil
+	}
+	s.mutex.Lock()
+	data, err := json.MarshalIndent(s, "", "  ")
+	s.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := ioutil.TempFile(filepath.Dir(s.path), ".dyngo-state")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err = tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err = tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), s.path)
+}
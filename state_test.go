@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dyngo")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	state, err := loadState(path)
+	assert.NoError(t, err, "a missing state file is not an error")
+	assert.True(t, state.NeedsVerify(24*time.Hour))
+
+	provider := &fakeProvider{record: "home.domain.com"}
+	state.Set(provider, "A", "192.0.2.1")
+	state.Verified()
+	assert.NoError(t, state.Save())
+
+	state, err = loadState(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "192.0.2.1", state.Get(provider, "A"))
+	assert.Equal(t, "", state.Get(provider, "AAAA"))
+	assert.False(t, state.NeedsVerify(24*time.Hour))
+	assert.True(t, state.NeedsVerify(0))
+
+	state.Forget(provider, "A")
+	assert.Equal(t, "", state.Get(provider, "A"))
+}
+
+func TestStateBadFile(t *testing.T) {
+	file, err := ioutil.TempFile("", "dyngo")
+	assert.NoError(t, err)
+	defer os.Remove(file.Name())
+	file.WriteString("{not json")
+	file.Close()
+
+	state, err := loadState(file.Name())
+	assert.Error(t, err)
+	assert.NotNil(t, state.Records)
+}
+
+func TestSyncRecordsSkipsUnchanged(t *testing.T) {
+	state, _ := loadState("")
+	provider := &fakeProvider{record: "home.domain.com", updates: map[string]string{}}
+	providers := dnsProvidersList{provider}
+
+	assert.False(t, anyFailed(syncRecords(context.Background(), providers, state, "A", "192.0.2.1", false)))
+	assert.Equal(t, 1, provider.calls)
+	assert.False(t, anyFailed(syncRecords(context.Background(), providers, state, "A", "192.0.2.1", false)))
+	assert.Equal(t, 1, provider.calls, "unchanged address should skip the provider")
+	assert.False(t, anyFailed(syncRecords(context.Background(), providers, state, "A", "192.0.2.1", true)))
+	assert.Equal(t, 2, provider.calls, "verify should call the provider")
+	assert.False(t, anyFailed(syncRecords(context.Background(), providers, state, "A", "192.0.2.2", false)))
+	assert.Equal(t, 3, provider.calls)
+}
+
+func TestSyncRecordsForgetsFailures(t *testing.T) {
+	state, _ := loadState("")
+	provider := &fakeProvider{record: "home.domain.com", updates: map[string]string{}}
+	providers := dnsProvidersList{provider}
+
+	assert.False(t, anyFailed(syncRecords(context.Background(), providers, state, "AAAA", "2001:db8::1", false)))
+	provider.fail = true
+	assert.True(t, anyFailed(syncRecords(context.Background(), providers, state, "AAAA", "2001:db8::2", false)))
+	assert.Equal(t, "", state.Get(provider, "AAAA"))
+}
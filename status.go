@@ -0,0 +1,128 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gesquive/dyngo/dns"
+)
+
+// providerStatus is the last known state of a provider record
+type providerStatus struct {
+	Provider    string     `json:"provider"`
+	Record      string     `json:"record"`
+	RecordType  string     `json:"record_type"`
+	Value       string     `json:"value,omitempty"`
+	LastAction  string     `json:"last_action,omitempty"`
+	LastAttempt *time.Time `json:"last_attempt,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// serviceStatus tracks what the service has been doing, for the api
+type serviceStatus struct {
+	records     map[string]providerStatus
+	addresses   map[string]string
+	lastCycle   time.Time
+	lastSuccess time.Time
+	mutex       sync.Mutex
+}
+
+// status of the running service
+var status = newServiceStatus()
+
+func newServiceStatus() *serviceStatus {
+	return &serviceStatus{
+		records:   map[string]providerStatus{},
+		addresses: map[string]string{},
+	}
+}
+
+// SetAddress records the detected address of recordType, and returns true
+// if it changed
+func (s *serviceStatus) SetAddress(recordType string, address string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.addresses[recordType] == address {
+		return false
+	}
+	s.addresses[recordType] = address
+	return true
+}
+
+// Address returns the detected address of recordType
+func (s *serviceStatus) Address(recordType string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.addresses[recordType]
+}
+
+// SyncAttempted records the outcome of a provider sync
+func (s *serviceStatus) SyncAttempted(provider dns.Provider, recordType string,
+	result dns.Result, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	key := stateKey(provider, recordType)
+	record := s.records[key]
+	now := time.Now()
+	record.LastAttempt = &now
+	record.LastAction = string(result.Action)
+	record.LastError = ""
+	if err != nil {
+		record.LastAction = "failed"
+		record.LastError = err.Error()
+	} else {
+		record.Value = result.NewValue
+	}
+	s.records[key] = record
+}
+
+// Record returns the status of the recordType record of provider
+func (s *serviceStatus) Record(provider dns.Provider, recordType string) providerStatus {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	record := s.records[stateKey(provider, recordType)]
+	record.Provider = string(provider.GetName())
+	record.Record = provider.GetRecord()
+	record.RecordType = recordType
+	return record
+}
+
+// CycleDone records the end of a sync cycle
+func (s *serviceStatus) CycleDone(failed bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lastCycle = time.Now()
+	if !failed {
+		s.lastSuccess = s.lastCycle
+	}
+}
+
+// Cycles returns when the last sync cycle finished, and when the last one
+// without failures finished
+func (s *serviceStatus) Cycles() (last time.Time, success time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.lastCycle, s.lastSuccess
+}
+
+// Records returns the status of the recordTypes records of the given
+// providers, using the sync state for records that have not been attempted
+func (s *serviceStatus) Records(providers dnsProvidersList, state *syncState,
+	recordTypes []string) []providerStatus {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	records := []providerStatus{}
+	for _, provider := range providers {
+		for _, recordType := range recordTypes {
+			record, ok := s.records[stateKey(provider, recordType)]
+			if !ok {
+				record.Value = state.Get(provider, recordType)
+			}
+			record.Provider = string(provider.GetName())
+			record.Record = provider.GetRecord()
+			record.RecordType = recordType
+			records = append(records, record)
+		}
+	}
+	return records
+}